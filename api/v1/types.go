@@ -1,5 +1,7 @@
 package v1
 
+import "time"
+
 type Provider string
 
 const (
@@ -23,11 +25,57 @@ type EncryptionSpec struct {
 	CustomerKeySHA256B64 string         `json:"customer_key_sha256_b64,omitempty"`
 }
 
+type StorageClass string
+
+const (
+	StorageHot     StorageClass = "hot"
+	StorageCool    StorageClass = "cool"
+	StorageCold    StorageClass = "cold"
+	StorageArchive StorageClass = "archive"
+)
+
+type RetentionMode string
+
+const (
+	RetentionGovernance RetentionMode = "governance"
+	RetentionCompliance RetentionMode = "compliance"
+)
+
+type RetentionSpec struct {
+	Mode        RetentionMode `json:"mode,omitempty"`
+	RetainUntil *time.Time    `json:"retain_until,omitempty"`
+	LegalHold   bool          `json:"legal_hold,omitempty"`
+}
+
+type TargetOptions struct {
+	ContentType   string            `json:"content_type,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	ExpiresMillis int64             `json:"expires_ms,omitempty"`
+	StorageClass  StorageClass      `json:"storage_class,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	IfNoneMatch   string            `json:"if_none_match,omitempty"`
+	IfMatch       string            `json:"if_match,omitempty"`
+	Retention     *RetentionSpec    `json:"retention,omitempty"`
+}
+
+// TargetRole says who writes a PUT target. The client writes primary targets; async targets are filled by
+// the verification worker from a confirmed primary.
+type TargetRole string
+
+const (
+	RolePrimary TargetRole = "primary"
+	RoleAsync   TargetRole = "async"
+)
+
 type TargetRef struct {
 	Provider   Provider        `json:"provider"`
 	Bucket     string          `json:"bucket"`
 	Key        string          `json:"key"`
 	Encryption *EncryptionSpec `json:"encryption"`
+	Options    *TargetOptions  `json:"options,omitempty"`
+	VersionID  string          `json:"version_id,omitempty"`
+	// Role only applies to PUT targets; empty means primary.
+	Role TargetRole `json:"role,omitempty"`
 }
 
 type PutObjectRequest struct {
@@ -35,14 +83,201 @@ type PutObjectRequest struct {
 	ContentType        string            `json:"content_type,omitempty"`
 	Metadata           map[string]string `json:"metadata,omitempty"`
 	ExpiresMillis      int64             `json:"expires_ms,omitempty"`
+	StorageClass       StorageClass      `json:"storage_class,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	IfNoneMatch        string            `json:"if_none_match,omitempty"`
+	Retention          *RetentionSpec    `json:"retention,omitempty"`
+	ContentLength      int64             `json:"content_length,omitempty"`
+	ContentMD5         string            `json:"content_md5,omitempty"`
+	// WriteQuorum is how many primary targets must be confirmed for the upload to commit. Zero means all.
+	WriteQuorum int `json:"write_quorum,omitempty"`
 }
 
 type PresignedUrl struct {
-	TargetRef TargetRef         `json:"target"`
-	URL       string            `json:"url"`
-	Headers   map[string]string `json:"headers,omitempty"`
+	TargetRef TargetRef `json:"target"`
+	URL       string    `json:"url"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Deprecated: Headers keeps only the first value of each header; use HeaderValues.
+	Headers       map[string]string   `json:"headers,omitempty"`
+	HeaderValues  map[string][]string `json:"header_values,omitempty"`
+	SignedHeaders []string            `json:"signed_headers,omitempty"`
+	VersionHeader string              `json:"version_header,omitempty"`
 }
 
 type PutObjectResponse struct {
+	Targets        []PresignedUrl `json:"targets"`
+	VerificationID string         `json:"verification_id,omitempty"`
+	UploadID       string         `json:"upload_id,omitempty"`
+	// Async lists the targets the gateway fills itself; they have no URL in Targets.
+	Async []TargetRef `json:"async,omitempty"`
+}
+
+type ResponseOverrides struct {
+	ContentDisposition string `json:"response_content_disposition,omitempty"`
+	ContentType        string `json:"response_content_type,omitempty"`
+	CacheControl       string `json:"response_cache_control,omitempty"`
+	ContentLanguage    string `json:"response_content_language,omitempty"`
+}
+
+type GetObjectRequest struct {
+	Targets       []TargetRef `json:"targets"`
+	ExpiresMillis int64       `json:"expires_ms,omitempty"`
+	Range         string      `json:"range,omitempty"`
+	ResponseOverrides
+}
+
+type GetObjectResponse struct {
+	Targets []PresignedUrl `json:"targets"`
+}
+
+type HeadObjectRequest struct {
+	Targets       []TargetRef `json:"targets"`
+	ExpiresMillis int64       `json:"expires_ms,omitempty"`
+}
+
+type HeadObjectResponse struct {
+	Targets []PresignedUrl `json:"targets"`
+}
+
+type DeleteObjectRequest struct {
+	Targets       []TargetRef `json:"targets"`
+	ExpiresMillis int64       `json:"expires_ms,omitempty"`
+}
+
+type DeleteObjectResponse struct {
+	Targets []PresignedUrl `json:"targets"`
+}
+
+type CopyObjectRequest struct {
+	Source        TargetRef   `json:"source"`
+	Destinations  []TargetRef `json:"destinations"`
+	ExpiresMillis int64       `json:"expires_ms,omitempty"`
+}
+
+type CopyObjectResponse struct {
 	Targets []PresignedUrl `json:"targets"`
 }
+
+type VerifyContentTypeRequest struct {
+	ContentType string      `json:"content_type"`
+	Targets     []TargetRef `json:"targets"`
+}
+
+type ContentTypeVerification struct {
+	TargetRef TargetRef `json:"target"`
+	Detected  string    `json:"detected,omitempty"`
+	Match     bool      `json:"match"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type VerifyContentTypeResponse struct {
+	Results []ContentTypeVerification `json:"results"`
+}
+
+type ReplicationState string
+
+const (
+	ReplicationComplete   ReplicationState = "complete"
+	ReplicationPartial    ReplicationState = "partial"
+	ReplicationMissing    ReplicationState = "missing"
+	ReplicationMismatched ReplicationState = "mismatched"
+)
+
+type ReplicaStatus struct {
+	TargetRef     TargetRef `json:"target"`
+	Exists        bool      `json:"exists"`
+	ContentLength int64     `json:"content_length,omitempty"`
+	ETag          string    `json:"etag,omitempty"`
+	Mismatch      string    `json:"mismatch,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+type ReplicationStatus struct {
+	VerificationID string           `json:"verification_id"`
+	State          ReplicationState `json:"state"`
+	Replicas       []ReplicaStatus  `json:"replicas"`
+	Attempts       int              `json:"attempts"`
+	CheckedAt      time.Time        `json:"checked_at"`
+}
+
+type UploadState string
+
+const (
+	UploadPending   UploadState = "pending"
+	UploadCommitted UploadState = "committed"
+	UploadFailed    UploadState = "failed"
+)
+
+type UploadSession struct {
+	ID            string           `json:"id"`
+	State         UploadState      `json:"state"`
+	Targets       []TargetRef      `json:"targets"`
+	ContentType   string           `json:"content_type,omitempty"`
+	ContentLength int64            `json:"content_length,omitempty"`
+	ContentMD5    string           `json:"content_md5,omitempty"`
+	WriteQuorum   int              `json:"write_quorum,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	ExpiresAt     time.Time        `json:"expires_at"`
+	CompletedAt   *time.Time       `json:"completed_at,omitempty"`
+	Replication   ReplicationState `json:"replication,omitempty"`
+	Replicas      []ReplicaStatus  `json:"replicas,omitempty"`
+	VerifiedAt    *time.Time       `json:"verified_at,omitempty"`
+	Error         string           `json:"error,omitempty"`
+}
+
+type UploadResult struct {
+	TargetRef  TargetRef `json:"target"`
+	StatusCode int       `json:"status_code"`
+	VersionID  string    `json:"version_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type CompleteUploadRequest struct {
+	Results []UploadResult `json:"results"`
+}
+
+type CompleteUploadResponse struct {
+	Upload UploadSession `json:"upload"`
+}
+
+// ProxyUploadResponse reports a body the gateway streamed to every target itself. ContentLength and
+// ContentMD5 describe the bytes the gateway received.
+type ProxyUploadResponse struct {
+	UploadID       string         `json:"upload_id,omitempty"`
+	VerificationID string         `json:"verification_id,omitempty"`
+	ContentLength  int64          `json:"content_length"`
+	ContentMD5     string         `json:"content_md5"`
+	Results        []UploadResult `json:"results"`
+	Upload         *UploadSession `json:"upload,omitempty"`
+}
+
+type ObjectStatusResponse struct {
+	UploadID    string           `json:"upload_id"`
+	UploadState UploadState      `json:"upload_state"`
+	Replication ReplicationState `json:"replication_state,omitempty"`
+	VerifiedAt  *time.Time       `json:"verified_at,omitempty"`
+	Replicas    []ReplicaStatus  `json:"replicas"`
+}
+
+type EventType string
+
+const (
+	EventUploadCommitted     EventType = "upload.committed"
+	EventUploadFailed        EventType = "upload.failed"
+	EventReplicationComplete EventType = "replication.complete"
+	EventReplicationFailed   EventType = "replication.failed"
+)
+
+// Event is the payload of an outbound webhook. UploadID doubles as the verification ID.
+type Event struct {
+	ID          string           `json:"id"`
+	Type        EventType        `json:"type"`
+	UploadID    string           `json:"upload_id"`
+	Targets     []TargetRef      `json:"targets"`
+	UploadState UploadState      `json:"upload_state,omitempty"`
+	Replication ReplicationState `json:"replication_state,omitempty"`
+	Replicas    []ReplicaStatus  `json:"replicas,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	OccurredAt  time.Time        `json:"occurred_at"`
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jordanharrington/bsync/internal/ingest"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"log"
+	"os"
+)
+
+func main() {
+	ctx := context.Background()
+
+	table := os.Getenv("BSYNC_UPLOADS_TABLE")
+	if table == "" {
+		log.Fatalf("BSYNC_UPLOADS_TABLE is required")
+	}
+
+	store, err := uploads.NewDynamoStore(ctx, table)
+	if err != nil {
+		log.Fatalf("failed to create upload store: %v", err)
+	}
+
+	lambda.Start(ingest.NewIngester(store).Handle)
+}
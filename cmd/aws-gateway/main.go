@@ -2,22 +2,106 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/awslabs/aws-lambda-go-api-proxy/core"
 	"github.com/awslabs/aws-lambda-go-api-proxy/gorillamux"
 	"github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/notify"
 	"github.com/jordanharrington/bsync/internal/server"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"github.com/jordanharrington/bsync/internal/verify"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// verifyGrace is how long past URL expiry the verifier waits for an upload to land.
+	verifyGrace = 15 * time.Minute
+	// webhookTimeout bounds how long an invocation waits on webhook deliveries, well inside the 29s API
+	// Gateway integration timeout. Deliveries that run out are dead-lettered.
+	webhookTimeout = 8 * time.Second
 )
 
 func main() {
-	r, err := server.NewRouter(context.Background(), v1.ProviderAWS)
+	ctx := context.Background()
+
+	var opts []server.RouterOption
+	if url := os.Getenv("BSYNC_VERIFY_QUEUE_URL"); url != "" {
+		q, err := verify.NewSQSQueue(ctx, url)
+		if err != nil {
+			log.Fatalf("failed to create verification queue: %v", err)
+		}
+		opts = append(opts, server.WithVerificationQueue(q, verifyGrace))
+	}
+	if table := os.Getenv("BSYNC_UPLOADS_TABLE"); table != "" {
+		store, err := uploads.NewDynamoStore(ctx, table)
+		if err != nil {
+			log.Fatalf("failed to create upload store: %v", err)
+		}
+		opts = append(opts, server.WithUploadSessions(store))
+	}
+
+	if path := os.Getenv("BSYNC_ALIASES_CONFIG"); path != "" {
+		aliases, err := server.LoadAliases(path)
+		if err != nil {
+			log.Fatalf("failed to load aliases: %v", err)
+		}
+		opts = append(opts, server.WithAliases(aliases))
+	}
+
+	var events *notify.Dispatcher
+	if path := os.Getenv("BSYNC_WEBHOOKS_CONFIG"); path != "" {
+		d, err := newDispatcher(path, os.Getenv("BSYNC_WEBHOOK_DEAD_LETTER_DIR"))
+		if err != nil {
+			log.Fatalf("failed to configure webhooks: %v", err)
+		}
+		events = d
+		opts = append(opts, server.WithNotifier(events))
+	}
+
+	r, err := server.NewRouter(ctx, v1.ProviderAWS, opts...)
 	if err != nil {
 		log.Fatalf("failed to create router: %v", err)
 	}
 
 	adapter := gorillamux.New(r)
 	lambda.Start(func(ctx context.Context, req core.SwitchableAPIGatewayRequest) (interface{}, error) {
-		return adapter.ProxyWithContext(ctx, req)
+		res, err := adapter.ProxyWithContext(ctx, req)
+		// Lambda freezes the process between invocations, so finish deliveries before returning.
+		if events != nil {
+			events.Wait()
+		}
+		return res, err
 	})
 }
+
+func newDispatcher(path, deadLetterDir string) (*notify.Dispatcher, error) {
+	endpoints, err := notify.LoadEndpoints(path)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []notify.Option{
+		notify.WithHTTPClient(&http.Client{Timeout: 3 * time.Second}),
+		notify.WithRetries(3, 500*time.Millisecond, 2*time.Second),
+		notify.WithDeliveryTimeout(webhookTimeout),
+	}
+	if deadLetterDir != "" {
+		// /tmp is the only writable path Lambda provides itself, and it is lost with the instance. Anything
+		// else is a mounted file system such as EFS.
+		if dir := filepath.Clean(deadLetterDir); dir == "/tmp" || strings.HasPrefix(dir, "/tmp/") {
+			return nil, fmt.Errorf("dead-letter directory %s is on the instance's temporary storage. use a mounted file system such as EFS", deadLetterDir)
+		}
+		dl, err := notify.NewFileDeadLetters(deadLetterDir)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, notify.WithDeadLetters(dl))
+	}
+	return notify.NewDispatcher(endpoints, opts...), nil
+}
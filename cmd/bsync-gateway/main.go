@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/notify"
+	"github.com/jordanharrington/bsync/internal/server"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"github.com/jordanharrington/bsync/internal/verify"
+)
+
+// verifyGrace is how long past URL expiry the verifier waits for an upload to land.
+const verifyGrace = 15 * time.Minute
+
+func main() {
+	addr := flag.String("addr", ":8080", "Address to listen on")
+	proxy := flag.Bool("proxy", true, "Enable /v1/proxy/put, which streams one client upload to every target")
+	maxUpload := flag.Int64("max-upload-size", 5<<30, "Largest object accepted by /v1/proxy/put, in bytes")
+	chunkSize := flag.Int("chunk-size", 1<<20, "Bytes buffered per proxy upload before they are written to every target")
+	queueDir := flag.String("queue-dir", "", "Directory of a file-backed verification queue (instead of SQS)")
+	uploadsDB := flag.String("uploads-db", "", "BoltDB file to keep upload sessions in (instead of DynamoDB)")
+
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		opts []server.RouterOption
+		q    verify.Queue
+		err  error
+	)
+	switch url := os.Getenv("BSYNC_VERIFY_QUEUE_URL"); {
+	case *queueDir != "":
+		q, err = verify.NewFileQueue(*queueDir)
+	case url != "":
+		q, err = verify.NewSQSQueue(ctx, url)
+	}
+	if err != nil {
+		log.Fatalf("failed to create verification queue: %v", err)
+	}
+	if q != nil {
+		opts = append(opts, server.WithVerificationQueue(q, verifyGrace))
+	}
+
+	var store uploads.Store
+	switch table := os.Getenv("BSYNC_UPLOADS_TABLE"); {
+	case *uploadsDB != "":
+		store, err = uploads.NewBoltStore(*uploadsDB)
+	case table != "":
+		store, err = uploads.NewDynamoStore(ctx, table)
+	}
+	if err != nil {
+		log.Fatalf("failed to create upload store: %v", err)
+	}
+	if store != nil {
+		opts = append(opts, server.WithUploadSessions(store))
+	}
+
+	if path := os.Getenv("BSYNC_ALIASES_CONFIG"); path != "" {
+		aliases, err := server.LoadAliases(path)
+		if err != nil {
+			log.Fatalf("failed to load aliases: %v", err)
+		}
+		opts = append(opts, server.WithAliases(aliases))
+	}
+
+	var events *notify.Dispatcher
+	if path := os.Getenv("BSYNC_WEBHOOKS_CONFIG"); path != "" {
+		endpoints, err := notify.LoadEndpoints(path)
+		if err != nil {
+			log.Fatalf("failed to configure webhooks: %v", err)
+		}
+
+		var nopts []notify.Option
+		if dir := os.Getenv("BSYNC_WEBHOOK_DEAD_LETTER_DIR"); dir != "" {
+			dl, err := notify.NewFileDeadLetters(dir)
+			if err != nil {
+				log.Fatalf("failed to configure webhooks: %v", err)
+			}
+			nopts = append(nopts, notify.WithDeadLetters(dl))
+		}
+		events = notify.NewDispatcher(endpoints, nopts...)
+		opts = append(opts, server.WithNotifier(events))
+	}
+
+	if *proxy {
+		opts = append(opts, server.WithProxyUploads(
+			server.WithProxyMaxSize(*maxUpload),
+			server.WithProxyChunkSize(*chunkSize),
+		))
+	}
+
+	r, err := server.NewRouter(ctx, v1.ProviderAWS, opts...)
+	if err != nil {
+		log.Fatalf("failed to create router: %v", err)
+	}
+
+	// No read or write timeout: proxy uploads stream large bodies at the client's pace.
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	log.Printf("listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("server stopped: %v", err)
+	}
+	<-closed
+	if events != nil {
+		events.Wait()
+	}
+}
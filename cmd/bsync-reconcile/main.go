@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/reconcile"
+)
+
+func main() {
+	policyPath := flag.String("policy", "", "JSON replication policy to reconcile")
+	apply := flag.Bool("apply", false, "Repair drifted replicas instead of only reporting them")
+	repairConflicts := flag.Bool("repair-conflicts", false, "Also overwrite replicas that differ with the most recently modified one")
+
+	flag.Parse()
+
+	if *policyPath == "" {
+		log.Fatalf("-policy is required")
+	}
+	policy, err := reconcile.LoadPolicy(*policyPath)
+	if err != nil {
+		log.Fatalf("failed to load policy: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	signers, err := presign.NewRegistry(ctx, v1.ProviderAWS)
+	if err != nil {
+		log.Fatalf("failed to create presigners: %v", err)
+	}
+
+	var opts []reconcile.Option
+	if *repairConflicts {
+		opts = append(opts, reconcile.WithConflictRepair())
+	}
+	r := reconcile.NewReconciler(signers, opts...)
+	rep, err := r.Scan(ctx, policy)
+	if err != nil {
+		log.Fatalf("scan failed: %v", err)
+	}
+	if *apply {
+		r.Apply(ctx, rep)
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if err := out.Encode(rep); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+	if rep.Failed > 0 {
+		os.Exit(1)
+	}
+}
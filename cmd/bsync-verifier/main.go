@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/notify"
+	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/reconcile"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"github.com/jordanharrington/bsync/internal/verify"
+)
+
+func main() {
+	queueURL := flag.String("queue-url", os.Getenv("BSYNC_VERIFY_QUEUE_URL"), "SQS queue URL to consume")
+	queueDLQ := flag.String("dead-letter-queue-url", os.Getenv("BSYNC_VERIFY_DEAD_LETTER_QUEUE_URL"), "SQS queue that jobs which cannot be decoded are moved to")
+	queueDir := flag.String("queue-dir", "", "Directory of a file-backed queue to consume (instead of SQS)")
+	workers := flag.Int("workers", 4, "Number of jobs verified concurrently")
+	minBackoff := flag.Duration("min-backoff", 5*time.Second, "Delay before re-checking a job with missing replicas")
+	maxBackoff := flag.Duration("max-backoff", time.Minute, "Longest delay between checks of a job")
+	uploadsTable := flag.String("uploads-table", os.Getenv("BSYNC_UPLOADS_TABLE"), "DynamoDB upload table to record statuses in")
+	webhooks := flag.String("webhooks", os.Getenv("BSYNC_WEBHOOKS_CONFIG"), "JSON file of webhook endpoints to notify of verification outcomes")
+	deadLetterDir := flag.String("dead-letter-dir", "", "Directory for webhook deliveries that exhausted their retries")
+	uploadsDB := flag.String("uploads-db", "", "BoltDB upload store to record statuses in (instead of DynamoDB)")
+
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		q   verify.Queue
+		err error
+	)
+	switch {
+	case *queueDir != "":
+		q, err = verify.NewFileQueue(*queueDir)
+	case *queueURL != "":
+		var sq *verify.SQSQueue
+		sq, err = verify.NewSQSQueue(ctx, *queueURL)
+		if err == nil {
+			sq.SetDeadLetterQueue(*queueDLQ)
+			q = sq
+		}
+	default:
+		log.Fatalf("one of -queue-url or -queue-dir is required")
+	}
+	if err != nil {
+		log.Fatalf("failed to open verification queue: %v", err)
+	}
+
+	signers, err := presign.NewRegistry(ctx, v1.ProviderAWS)
+	if err != nil {
+		log.Fatalf("failed to create presigners: %v", err)
+	}
+
+	var store uploads.Store
+	switch {
+	case *uploadsDB != "":
+		store, err = uploads.NewBoltStore(*uploadsDB)
+	case *uploadsTable != "":
+		store, err = uploads.NewDynamoStore(ctx, *uploadsTable)
+	}
+	if err != nil {
+		log.Fatalf("failed to open upload store: %v", err)
+	}
+
+	var events *notify.Dispatcher
+	if *webhooks != "" {
+		endpoints, err := notify.LoadEndpoints(*webhooks)
+		if err != nil {
+			log.Fatalf("failed to load webhooks: %v", err)
+		}
+
+		var opts []notify.Option
+		if *deadLetterDir != "" {
+			dl, err := notify.NewFileDeadLetters(*deadLetterDir)
+			if err != nil {
+				log.Fatalf("failed to open dead-letter directory: %v", err)
+			}
+			opts = append(opts, notify.WithDeadLetters(dl))
+		}
+		events = notify.NewDispatcher(endpoints, opts...)
+		// Let in-flight deliveries finish on shutdown.
+		defer events.Wait()
+	}
+
+	var mu sync.Mutex
+	out := json.NewEncoder(os.Stdout)
+	v := verify.NewReplicationVerifier(signers,
+		verify.WithBackoff(*minBackoff, *maxBackoff),
+		// Async targets are filled from a confirmed primary.
+		verify.WithRepairer(reconcile.NewReconciler(signers)),
+		// Replicas whose content does not sniff as their declared type are reported as mismatched, so the
+		// upload store and webhooks flag them.
+		verify.WithContentTypeCheck(verify.NewContentTypeVerifier(signers,
+			verify.WithOnMismatch(func(_ context.Context, res verify.ContentTypeResult) {
+				log.Printf("flagged %s/%s/%s: declared %s, detected %s",
+					res.Target.Provider, res.Target.Bucket, res.Target.Key, res.Declared, res.Detected)
+			}),
+		)),
+		verify.WithOnStatus(func(ctx context.Context, st v1.ReplicationStatus) {
+			if store != nil {
+				if err := uploads.RecordStatus(ctx, store, st); err != nil {
+					log.Printf("failed to record status for %s: %v", st.VerificationID, err)
+				}
+			}
+			if events != nil {
+				events.Notify(ctx, notify.ReplicationEvent(st))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err := out.Encode(st); err != nil {
+				log.Printf("failed to write status for %s: %v", st.VerificationID, err)
+			}
+		}),
+	)
+
+	if err := v.Consume(ctx, q, *workers); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("verifier stopped: %v", err)
+	}
+}
@@ -138,13 +138,19 @@ func main() {
 }
 
 func putWithPresignedURL(ctx context.Context, t v1.PresignedUrl, data []byte, contentType string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.URL, bytes.NewReader(data))
+	if time.Now().After(t.ExpiresAt) {
+		return fmt.Errorf("presigned url expired at %s", t.ExpiresAt.Format(time.RFC3339))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, t.Method, t.URL, bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
 
-	for k, v := range t.Headers {
-		req.Header.Set(k, v)
+	for k, vals := range t.HeaderValues {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
 	}
 	req.Header.Set("Content-Type", contentType)
 	res, err := http.DefaultClient.Do(req)
@@ -0,0 +1,273 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	lambdaevents "github.com/aws/aws-lambda-go/events"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"github.com/jordanharrington/bsync/internal/verify"
+)
+
+// ObjectCreated is a provider's notice that an object was written.
+type ObjectCreated struct {
+	Target v1.TargetRef
+	Size   int64
+	ETag   string
+	Time   time.Time
+}
+
+// eventBridgeDetail is the detail of an EventBridge "Object Created" event from S3.
+type eventBridgeDetail struct {
+	Bucket struct {
+		Name string `json:"name"`
+	} `json:"bucket"`
+	Object struct {
+		Key       string `json:"key"`
+		Size      int64  `json:"size"`
+		ETag      string `json:"etag"`
+		VersionID string `json:"version-id"`
+	} `json:"object"`
+}
+
+// envelope holds the fields used to tell the supported payloads apart.
+type envelope struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+	DetailType string `json:"detail-type"`
+	Source     string `json:"source"`
+	Event      string `json:"Event"`
+}
+
+// Ingester records S3 ObjectCreated notifications against the pending upload that wrote each object.
+// Because an upload and its verification job share an ID, the recorded replicas are also what
+// /v1/objects/status reports for the job.
+type Ingester struct {
+	store uploads.Store
+}
+
+func NewIngester(store uploads.Store) *Ingester {
+	return &Ingester{store: store}
+}
+
+// Handle accepts an S3 notification, an SQS batch of S3 notifications or EventBridge events, or a single
+// EventBridge event. It is safe to replay: recording the same object twice has no further effect. Messages
+// of an SQS batch are handled one by one, and only those that fail are reported back for redelivery; the
+// event source mapping must enable ReportBatchItemFailures.
+func (in *Ingester) Handle(ctx context.Context, raw json.RawMessage) (*lambdaevents.SQSEventResponse, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %v", err)
+	}
+	if len(env.Records) > 0 && env.Records[0].EventSource == "aws:sqs" {
+		var batch lambdaevents.SQSEvent
+		if err := json.Unmarshal(raw, &batch); err != nil {
+			return nil, fmt.Errorf("failed to decode sqs event: %v", err)
+		}
+		return in.handleBatch(ctx, batch), nil
+	}
+
+	return nil, in.handle(ctx, raw)
+}
+
+func (in *Ingester) handleBatch(ctx context.Context, batch lambdaevents.SQSEvent) *lambdaevents.SQSEventResponse {
+	resp := &lambdaevents.SQSEventResponse{BatchItemFailures: []lambdaevents.SQSBatchItemFailure{}}
+	for _, msg := range batch.Records {
+		if err := in.handle(ctx, json.RawMessage(msg.Body)); err != nil {
+			log.Printf("failed to ingest sqs message %s: %v", msg.MessageId, err)
+			resp.BatchItemFailures = append(resp.BatchItemFailures, lambdaevents.SQSBatchItemFailure{ItemIdentifier: msg.MessageId})
+		}
+	}
+	return resp
+}
+
+func (in *Ingester) handle(ctx context.Context, raw json.RawMessage) error {
+	objs, err := ParseObjectCreated(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objs {
+		if err := in.Record(ctx, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseObjectCreated extracts every ObjectCreated notice from raw. Other event types, including the
+// s3:TestEvent S3 sends when notifications are configured, are skipped.
+func ParseObjectCreated(raw json.RawMessage) ([]ObjectCreated, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %v", err)
+	}
+
+	switch {
+	case env.Event == "s3:TestEvent":
+		return nil, nil
+	case env.DetailType != "":
+		return parseEventBridge(raw)
+	case len(env.Records) > 0 && env.Records[0].EventSource == "aws:sqs":
+		var batch lambdaevents.SQSEvent
+		if err := json.Unmarshal(raw, &batch); err != nil {
+			return nil, fmt.Errorf("failed to decode sqs event: %v", err)
+		}
+
+		var out []ObjectCreated
+		for _, msg := range batch.Records {
+			objs, err := ParseObjectCreated(json.RawMessage(msg.Body))
+			if err != nil {
+				return nil, fmt.Errorf("sqs message %s: %v", msg.MessageId, err)
+			}
+			out = append(out, objs...)
+		}
+		return out, nil
+	case len(env.Records) > 0 && env.Records[0].EventSource == "aws:s3":
+		var ev lambdaevents.S3Event
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return nil, fmt.Errorf("failed to decode s3 event: %v", err)
+		}
+
+		var out []ObjectCreated
+		for _, r := range ev.Records {
+			if !strings.HasPrefix(r.EventName, "ObjectCreated:") {
+				continue
+			}
+			out = append(out, ObjectCreated{
+				Target: s3Target(r.S3.Bucket.Name, r.S3.Object.URLDecodedKey, r.S3.Object.VersionID),
+				Size:   r.S3.Object.Size,
+				ETag:   r.S3.Object.ETag,
+				Time:   r.EventTime,
+			})
+		}
+		return out, nil
+	default:
+		return nil, errors.New("unrecognised event payload")
+	}
+}
+
+func parseEventBridge(raw json.RawMessage) ([]ObjectCreated, error) {
+	var ev lambdaevents.EventBridgeEvent
+	if err := json.Unmarshal(raw, &ev); err != nil {
+		return nil, fmt.Errorf("failed to decode eventbridge event: %v", err)
+	}
+	if ev.Source != "aws.s3" || ev.DetailType != "Object Created" {
+		return nil, nil
+	}
+
+	var d eventBridgeDetail
+	if err := json.Unmarshal(ev.Detail, &d); err != nil {
+		return nil, fmt.Errorf("failed to decode eventbridge detail: %v", err)
+	}
+	// EventBridge URL-encodes keys the same way S3 notifications do.
+	key, err := url.QueryUnescape(d.Object.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode object key %s: %v", d.Object.Key, err)
+	}
+
+	return []ObjectCreated{{
+		Target: s3Target(d.Bucket.Name, key, d.Object.VersionID),
+		Size:   d.Object.Size,
+		ETag:   d.Object.ETag,
+		Time:   ev.Time,
+	}}, nil
+}
+
+func s3Target(bucket, key, versionID string) v1.TargetRef {
+	return v1.TargetRef{Provider: v1.ProviderAWS, Bucket: bucket, Key: key, VersionID: versionID}
+}
+
+// errStale aborts an Update for an event that no longer applies to the session.
+var errStale = errors.New("stale event")
+
+// Record marks o's target as present on the newest upload that wrote it. Objects written outside the
+// gateway have no upload and are ignored, as are events from before the upload was created and events for
+// uploads that are no longer pending or in verification.
+func (in *Ingester) Record(ctx context.Context, o ObjectCreated) error {
+	session, err := in.store.FindByTarget(ctx, o.Target)
+	if errors.Is(err, uploads.ErrNotFound) {
+		log.Printf("ignoring %s: no upload writes it", uploads.TargetKey(o.Target))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var reason string
+	_, err = in.store.Update(ctx, session.ID, func(s *v1.UploadSession) error {
+		if reason = staleReason(s, o); reason != "" {
+			return errStale
+		}
+		recordReplica(s, o)
+		return nil
+	})
+	if errors.Is(err, errStale) {
+		log.Printf("ignoring %s for upload %s: %s", uploads.TargetKey(o.Target), session.ID, reason)
+		return nil
+	}
+	return err
+}
+
+// staleReason returns why o does not apply to s, or "" if it does. An event older than the session is a
+// late or replayed notification for an earlier write of the key. Only pending uploads and committed uploads
+// whose replication has not completed are still waiting on replicas.
+func staleReason(s *v1.UploadSession, o ObjectCreated) string {
+	if !o.Time.IsZero() && o.Time.Before(s.CreatedAt) {
+		return fmt.Sprintf("event at %s predates the upload", o.Time.UTC().Format(time.RFC3339))
+	}
+	switch s.State {
+	case v1.UploadPending:
+		return ""
+	case v1.UploadCommitted:
+		if s.Replication == v1.ReplicationComplete {
+			return "replication is complete"
+		}
+		return ""
+	}
+	return fmt.Sprintf("upload is %s", s.State)
+}
+
+// recordReplica sets the replica of s for o's target from the event, comparing the size with the one the
+// client declared. Digests are left to the HEAD verifier: an event does not say whether the ETag is an MD5,
+// so a digest mismatch the verifier found is kept.
+func recordReplica(s *v1.UploadSession, o ObjectCreated) {
+	if len(s.Replicas) == 0 {
+		s.Replicas = make([]v1.ReplicaStatus, len(s.Targets))
+		for i, t := range s.Targets {
+			s.Replicas[i] = v1.ReplicaStatus{TargetRef: t}
+		}
+	}
+
+	key := uploads.TargetKey(o.Target)
+	for i, r := range s.Replicas {
+		if uploads.TargetKey(r.TargetRef) != key {
+			continue
+		}
+
+		r.TargetRef.VersionID = o.Target.VersionID
+		r.Exists = true
+		r.ContentLength = o.Size
+		r.ETag = o.ETag
+		r.Error = ""
+		if s.ContentLength > 0 && o.Size != s.ContentLength {
+			r.Mismatch = fmt.Sprintf("content length %d, expected %d", o.Size, s.ContentLength)
+		} else if strings.HasPrefix(r.Mismatch, "content length ") {
+			r.Mismatch = ""
+		}
+		s.Replicas[i] = r
+	}
+
+	at := o.Time.UTC()
+	s.Replication = verify.Summarize(s.Replicas)
+	s.VerifiedAt = &at
+}
@@ -0,0 +1,187 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lambdaevents "github.com/aws/aws-lambda-go/events"
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestIngest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ingest")
+}
+
+func fixture(name string) json.RawMessage {
+	bs, err := os.ReadFile(filepath.Join("testdata", name))
+	Expect(err).NotTo(HaveOccurred())
+	return bs
+}
+
+var _ = Describe("Ingester", func() {
+	const key = "photos/caf\u00e9 1.png"
+
+	b1 := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: key}
+	b2 := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: key}
+
+	type parseTestCase struct {
+		fixture       string
+		expectTargets []v1.TargetRef
+	}
+
+	DescribeTable("ParseObjectCreated",
+		func(tc parseTestCase) {
+			objs, err := ParseObjectCreated(fixture(tc.fixture))
+			Expect(err).NotTo(HaveOccurred())
+
+			targets := make([]v1.TargetRef, len(objs))
+			for i, o := range objs {
+				targets[i] = o.Target
+			}
+			Expect(targets).To(Equal(tc.expectTargets))
+		},
+
+		Entry("s3 notification skips removals and decodes keys", parseTestCase{
+			fixture:       "s3.json",
+			expectTargets: []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: key, VersionID: "v1"}},
+		}),
+		Entry("sqs batch skips s3:TestEvent", parseTestCase{
+			fixture:       "sqs.json",
+			expectTargets: []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: key, VersionID: "v7"}},
+		}),
+		Entry("eventbridge object created", parseTestCase{
+			fixture:       "eventbridge.json",
+			expectTargets: []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: key, VersionID: "v2"}},
+		}),
+	)
+
+	It("rejects payloads it does not recognise", func() {
+		_, err := ParseObjectCreated(json.RawMessage(`{"hello":"world"}`))
+		Expect(err).To(MatchError("unrecognised event payload"))
+	})
+
+	It("records replayed events against the pending upload", func() {
+		ctx := context.Background()
+		store := uploads.NewMemoryStore()
+		Expect(store.Create(ctx, v1.UploadSession{
+			ID:            "u1",
+			State:         v1.UploadPending,
+			Targets:       []v1.TargetRef{b1, b2},
+			ContentLength: 4,
+		})).To(Succeed())
+
+		in := NewIngester(store)
+		handle := func(name string) {
+			resp, err := in.Handle(ctx, fixture(name))
+			Expect(err).NotTo(HaveOccurred())
+			if resp != nil {
+				Expect(resp.BatchItemFailures).To(BeEmpty())
+			}
+		}
+
+		handle("s3.json")
+		s, err := store.Get(ctx, "u1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Replication).To(Equal(v1.ReplicationPartial))
+		Expect(s.Replicas[0].Exists).To(BeTrue())
+		Expect(s.Replicas[0].ETag).To(Equal("d41d8cd98f00b204e9800998ecf8427e"))
+		Expect(s.Replicas[0].TargetRef.VersionID).To(Equal("v1"))
+
+		handle("sqs.json")
+		s, err = store.Get(ctx, "u1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Replication).To(Equal(v1.ReplicationMismatched))
+		Expect(s.Replicas[1].Mismatch).To(Equal("content length 3, expected 4"))
+		Expect(s.VerifiedAt).NotTo(BeNil())
+
+		handle("s3.json")
+		replayed, err := store.Get(ctx, "u1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replayed.Replicas).To(Equal(s.Replicas))
+	})
+
+	It("ignores objects written outside the gateway", func() {
+		in := NewIngester(uploads.NewMemoryStore())
+		resp, err := in.Handle(context.Background(), fixture("eventbridge.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp).To(BeNil())
+	})
+
+	It("reports only the sqs messages it could not ingest", func() {
+		ctx := context.Background()
+		store := uploads.NewMemoryStore()
+		Expect(store.Create(ctx, v1.UploadSession{ID: "u1", State: v1.UploadPending, Targets: []v1.TargetRef{b1, b2}})).To(Succeed())
+
+		var batch lambdaevents.SQSEvent
+		Expect(json.Unmarshal(fixture("sqs.json"), &batch)).To(Succeed())
+		batch.Records[0].Body = "not json"
+		raw, err := json.Marshal(batch)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err := NewIngester(store).Handle(ctx, raw)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.BatchItemFailures).To(ConsistOf(lambdaevents.SQSBatchItemFailure{ItemIdentifier: batch.Records[0].MessageId}))
+
+		s, err := store.Get(ctx, "u1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Replicas[1].Exists).To(BeTrue())
+	})
+
+	It("keeps a digest mismatch found by the verifier", func() {
+		ctx := context.Background()
+		store := uploads.NewMemoryStore()
+		Expect(store.Create(ctx, v1.UploadSession{
+			ID:      "u1",
+			State:   v1.UploadPending,
+			Targets: []v1.TargetRef{b1, b2},
+			Replicas: []v1.ReplicaStatus{
+				{TargetRef: b1, Exists: true, Mismatch: "content md5 abc, expected def"},
+				{TargetRef: b2},
+			},
+		})).To(Succeed())
+
+		_, err := NewIngester(store).Handle(ctx, fixture("s3.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		s, err := store.Get(ctx, "u1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Replicas[0].Mismatch).To(Equal("content md5 abc, expected def"))
+		Expect(s.Replication).To(Equal(v1.ReplicationMismatched))
+	})
+
+	DescribeTable("ignores events that do not apply to the upload",
+		func(s v1.UploadSession) {
+			ctx := context.Background()
+			store := uploads.NewMemoryStore()
+			s.ID = "u1"
+			s.Targets = []v1.TargetRef{b1, b2}
+			Expect(store.Create(ctx, s)).To(Succeed())
+
+			_, err := NewIngester(store).Handle(ctx, fixture("s3.json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			got, err := store.Get(ctx, "u1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got.Replicas).To(Equal(s.Replicas))
+			Expect(got.VerifiedAt).To(BeNil())
+		},
+		Entry("event from an earlier write", v1.UploadSession{
+			State:     v1.UploadPending,
+			CreatedAt: time.Date(2030, 1, 1, 0, 1, 0, 0, time.UTC),
+		}),
+		Entry("failed upload", v1.UploadSession{State: v1.UploadFailed}),
+		Entry("verified upload", v1.UploadSession{
+			State:       v1.UploadCommitted,
+			Replication: v1.ReplicationComplete,
+			Replicas:    []v1.ReplicaStatus{{TargetRef: b1, Exists: true}, {TargetRef: b2, Exists: true}},
+		}),
+	)
+})
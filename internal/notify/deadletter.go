@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+// DeadLetter is a delivery that exhausted its retries. It holds the full event so it can be replayed.
+type DeadLetter struct {
+	Endpoint string    `json:"endpoint"`
+	Event    v1.Event  `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetters stores failed deliveries.
+type DeadLetters interface {
+	Put(ctx context.Context, dl DeadLetter) error
+}
+
+// MemoryDeadLetters keeps failed deliveries in process, for tests.
+type MemoryDeadLetters struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func NewMemoryDeadLetters() *MemoryDeadLetters {
+	return &MemoryDeadLetters{}
+}
+
+func (m *MemoryDeadLetters) Put(_ context.Context, dl DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.letters = append(m.letters, dl)
+	return nil
+}
+
+// List returns the stored deliveries in the order they failed.
+func (m *MemoryDeadLetters) List() []DeadLetter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]DeadLetter(nil), m.letters...)
+}
+
+// FileDeadLetters writes each failed delivery as a JSON file in a directory.
+type FileDeadLetters struct {
+	dir string
+}
+
+func NewFileDeadLetters(dir string) (*FileDeadLetters, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %v", err)
+	}
+	return &FileDeadLetters{dir: dir}, nil
+}
+
+// Put writes dl to a temporary file and renames it into place, so a reader never sees a partial letter.
+// File names sort in the order deliveries failed.
+func (f *FileDeadLetters) Put(_ context.Context, dl DeadLetter) error {
+	bs, err := json.Marshal(dl)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".letter-*")
+	if err != nil {
+		return fmt.Errorf("failed to write dead letter: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write dead letter: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write dead letter: %v", err)
+	}
+
+	name := fmt.Sprintf("%020d-%s.json", dl.FailedAt.UnixNano(), dl.Event.ID)
+	if err := os.Rename(tmp.Name(), filepath.Join(f.dir, name)); err != nil {
+		return fmt.Errorf("failed to write dead letter: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+// Headers set on every webhook delivery.
+const (
+	HeaderEvent     = "X-Bsync-Event"
+	HeaderDelivery  = "X-Bsync-Delivery"
+	HeaderTimestamp = "X-Bsync-Timestamp"
+	HeaderSignature = "X-Bsync-Signature"
+)
+
+// Notifier receives upload and replication events.
+type Notifier interface {
+	Notify(ctx context.Context, ev v1.Event)
+}
+
+// Endpoint is a webhook subscriber. An empty Events list subscribes to every event type.
+type Endpoint struct {
+	URL    string         `json:"url"`
+	Secret string         `json:"secret"`
+	Events []v1.EventType `json:"events,omitempty"`
+}
+
+func (e Endpoint) wants(t v1.EventType) bool {
+	return len(e.Events) == 0 || slices.Contains(e.Events, t)
+}
+
+// LoadEndpoints reads a JSON array of endpoints from path.
+func LoadEndpoints(path string) ([]Endpoint, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var eps []Endpoint
+	if err := json.Unmarshal(b, &eps); err != nil {
+		return nil, fmt.Errorf("failed to parse webhooks: %v", err)
+	}
+	for _, ep := range eps {
+		if !strings.HasPrefix(ep.URL, "https://") && !strings.HasPrefix(ep.URL, "http://") {
+			return nil, fmt.Errorf("webhook url must be http or https: %q", ep.URL)
+		}
+		if ep.Secret == "" {
+			return nil, fmt.Errorf("webhook %s has no secret", ep.URL)
+		}
+	}
+	return eps, nil
+}
+
+// Dispatcher delivers events to webhook endpoints in the background. Each delivery is signed with the
+// endpoint's secret and retried with exponential backoff; deliveries that still fail are handed to the
+// dead-letter store.
+type Dispatcher struct {
+	endpoints   []Endpoint
+	client      *http.Client
+	attempts    int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	timeout     time.Duration
+	deadLetters DeadLetters
+	wg          sync.WaitGroup
+}
+
+// Option mutates a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithHTTPClient sets the client used for deliveries.
+func WithHTTPClient(c *http.Client) Option {
+	return func(d *Dispatcher) { d.client = c }
+}
+
+// WithRetries sets how many times a delivery is attempted and the first and longest delay between attempts.
+func WithRetries(attempts int, minDelay, maxDelay time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.attempts = attempts
+		d.minBackoff = minDelay
+		d.maxBackoff = maxDelay
+	}
+}
+
+// WithDeliveryTimeout bounds the total time of a delivery, retries and backoff included. A delivery still
+// failing when it runs out is dead-lettered. Zero means no bound beyond the retry count.
+func WithDeliveryTimeout(t time.Duration) Option {
+	return func(d *Dispatcher) { d.timeout = t }
+}
+
+// WithDeadLetters sets where deliveries that exhausted their retries are kept. Without it they are logged.
+func WithDeadLetters(dl DeadLetters) Option {
+	return func(d *Dispatcher) { d.deadLetters = dl }
+}
+
+func NewDispatcher(endpoints []Endpoint, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		endpoints:  endpoints,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		attempts:   4,
+		minBackoff: time.Second,
+		maxBackoff: 8 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Notify starts a delivery of ev to every endpoint subscribed to its type and returns without waiting.
+// Deliveries outlive ctx's cancellation so that a finished HTTP request does not abort them.
+func (d *Dispatcher) Notify(ctx context.Context, ev v1.Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("failed to encode event %s: %v", ev.ID, err)
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	for _, ep := range d.endpoints {
+		if !ep.wants(ev.Type) {
+			continue
+		}
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliver(ctx, ep, ev, body)
+		}()
+	}
+}
+
+// Wait blocks until every delivery started so far has succeeded or been dead-lettered.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, ep Endpoint, ev v1.Event, body []byte) {
+	attemptCtx := ctx
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	delay := d.minBackoff
+	attempt := 1
+	for {
+		retry, err := d.post(attemptCtx, ep, ev, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= d.attempts || attemptCtx.Err() != nil {
+			d.deadLetter(ctx, ep, ev, attempt, err)
+			return
+		}
+
+		select {
+		case <-attemptCtx.Done():
+			d.deadLetter(ctx, ep, ev, attempt, err)
+			return
+		case <-time.After(delay):
+		}
+		attempt++
+		delay *= 2
+		if delay > d.maxBackoff {
+			delay = d.maxBackoff
+		}
+	}
+}
+
+func (d *Dispatcher) deadLetter(ctx context.Context, ep Endpoint, ev v1.Event, attempts int, err error) {
+	dl := DeadLetter{
+		Endpoint: ep.URL,
+		Event:    ev,
+		Attempts: attempts,
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+	}
+	if d.deadLetters == nil {
+		log.Printf("dropped event %s for %s after %d attempts: %v", ev.ID, ep.URL, dl.Attempts, err)
+		return
+	}
+	if err := d.deadLetters.Put(ctx, dl); err != nil {
+		log.Printf("failed to dead-letter event %s for %s: %v", ev.ID, ep.URL, err)
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying: network errors,
+// timeouts, throttling and server errors are; any other rejection is not.
+func (d *Dispatcher) post(ctx context.Context, ep Endpoint, ev v1.Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(ev.Type))
+	req.Header.Set(HeaderDelivery, ev.ID)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(ep.Secret, ts, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	switch {
+	case res.StatusCode/100 == 2:
+		return false, nil
+	case res.StatusCode == http.StatusRequestTimeout, res.StatusCode == http.StatusTooManyRequests, res.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", res.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", res.Status)
+	}
+}
+
+// Sign returns the X-Bsync-Signature value for body sent at ts: the hex HMAC-SHA256 of "<ts>.<body>" keyed
+// with secret.
+func Sign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrInvalidSignature is returned by VerifySignature for deliveries that were not signed with the secret.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// VerifySignature checks a received delivery against secret and rejects deliveries signed more than
+// maxAge ago, which bounds replays.
+func VerifySignature(secret string, h http.Header, body []byte, maxAge time.Duration) error {
+	ts := h.Get(HeaderTimestamp)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp %q", ErrInvalidSignature, ts)
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > maxAge || age < -maxAge {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(h.Get(HeaderSignature)), []byte(Sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// eventID derives an event ID from the upload it is about, its type and the attempt that produced it,
+// so that an event raised again for the same outcome, such as by a redelivered job, keeps its ID and
+// receivers can deduplicate it.
+func eventID(uploadID string, t v1.EventType, attempt int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", uploadID, t, attempt)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// UploadEvent describes the outcome of a completed upload session. A session completes once, so its
+// events use attempt 1.
+func UploadEvent(s v1.UploadSession) v1.Event {
+	t := v1.EventUploadCommitted
+	if s.State == v1.UploadFailed {
+		t = v1.EventUploadFailed
+	}
+
+	return v1.Event{
+		ID:          eventID(s.ID, t, 1),
+		Type:        t,
+		UploadID:    s.ID,
+		Targets:     s.Targets,
+		UploadState: s.State,
+		Replication: s.Replication,
+		Replicas:    s.Replicas,
+		Error:       s.Error,
+		OccurredAt:  time.Now().UTC(),
+	}
+}
+
+// ReplicationEvent describes the final status of a verification job. Its ID covers the number of checks
+// the job took.
+func ReplicationEvent(st v1.ReplicationStatus) v1.Event {
+	t := v1.EventReplicationComplete
+	if st.State != v1.ReplicationComplete {
+		t = v1.EventReplicationFailed
+	}
+
+	targets := make([]v1.TargetRef, len(st.Replicas))
+	for i, r := range st.Replicas {
+		targets[i] = r.TargetRef
+	}
+
+	return v1.Event{
+		ID:          eventID(st.VerificationID, t, st.Attempts),
+		Type:        t,
+		UploadID:    st.VerificationID,
+		Targets:     targets,
+		Replication: st.State,
+		Replicas:    st.Replicas,
+		OccurredAt:  time.Now().UTC(),
+	}
+}
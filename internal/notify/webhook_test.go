@@ -0,0 +1,271 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestNotify(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Notify")
+}
+
+const secret = "s3cr3t"
+
+var _ = Describe("Dispatcher", func() {
+	var (
+		ctx      context.Context
+		srv      *httptest.Server
+		mu       sync.Mutex
+		statuses []int
+		received []v1.Event
+		calls    int
+	)
+
+	target := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "b1", Key: "k1"}
+	session := v1.UploadSession{ID: "u1", State: v1.UploadCommitted, Targets: []v1.TargetRef{target}}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		statuses = nil
+		received = nil
+		calls = 0
+
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			Expect(VerifySignature(secret, r.Header, body, time.Minute)).To(Succeed())
+
+			mu.Lock()
+			defer mu.Unlock()
+			status := http.StatusNoContent
+			if calls < len(statuses) {
+				status = statuses[calls]
+			}
+			calls++
+			if status/100 == 2 {
+				var ev v1.Event
+				Expect(json.Unmarshal(body, &ev)).To(Succeed())
+				Expect(r.Header.Get(HeaderEvent)).To(Equal(string(ev.Type)))
+				Expect(r.Header.Get(HeaderDelivery)).To(Equal(ev.ID))
+				received = append(received, ev)
+			}
+			w.WriteHeader(status)
+		}))
+		DeferCleanup(srv.Close)
+	})
+
+	dispatcher := func(dl DeadLetters, eps ...Endpoint) *Dispatcher {
+		if len(eps) == 0 {
+			eps = []Endpoint{{URL: srv.URL, Secret: secret}}
+		}
+		return NewDispatcher(eps,
+			WithHTTPClient(srv.Client()),
+			WithRetries(3, time.Millisecond, 2*time.Millisecond),
+			WithDeadLetters(dl),
+		)
+	}
+
+	It("delivers a signed event", func() {
+		d := dispatcher(NewMemoryDeadLetters())
+		d.Notify(ctx, UploadEvent(session))
+		d.Wait()
+
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Type).To(Equal(v1.EventUploadCommitted))
+		Expect(received[0].UploadID).To(Equal("u1"))
+		Expect(received[0].Targets).To(ConsistOf(target))
+	})
+
+	It("only delivers subscribed event types", func() {
+		d := dispatcher(NewMemoryDeadLetters(), Endpoint{
+			URL:    srv.URL,
+			Secret: secret,
+			Events: []v1.EventType{v1.EventReplicationFailed},
+		})
+		d.Notify(ctx, UploadEvent(session))
+		d.Notify(ctx, ReplicationEvent(v1.ReplicationStatus{VerificationID: "u1", State: v1.ReplicationMissing}))
+		d.Wait()
+
+		Expect(received).To(ConsistOf(HaveField("Type", v1.EventReplicationFailed)))
+	})
+
+	It("retries server errors until the endpoint accepts", func() {
+		statuses = []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK}
+		dl := NewMemoryDeadLetters()
+
+		d := dispatcher(dl)
+		d.Notify(ctx, UploadEvent(session))
+		d.Wait()
+
+		Expect(calls).To(Equal(3))
+		Expect(received).To(HaveLen(1))
+		Expect(dl.List()).To(BeEmpty())
+	})
+
+	It("dead-letters a delivery that exhausts its retries", func() {
+		statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+		dl := NewMemoryDeadLetters()
+
+		d := dispatcher(dl)
+		ev := UploadEvent(session)
+		d.Notify(ctx, ev)
+		d.Wait()
+
+		Expect(calls).To(Equal(3))
+		Expect(dl.List()).To(ConsistOf(And(
+			HaveField("Endpoint", srv.URL),
+			HaveField("Event.ID", ev.ID),
+			HaveField("Attempts", 3),
+			HaveField("Error", "webhook returned 502 Bad Gateway"),
+		)))
+	})
+
+	It("dead-letters a delivery that runs out of time before its retries", func() {
+		statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+		dl := NewMemoryDeadLetters()
+
+		d := NewDispatcher([]Endpoint{{URL: srv.URL, Secret: secret}},
+			WithHTTPClient(srv.Client()),
+			WithRetries(3, time.Hour, time.Hour),
+			WithDeliveryTimeout(20*time.Millisecond),
+			WithDeadLetters(dl),
+		)
+		start := time.Now()
+		d.Notify(ctx, UploadEvent(session))
+		d.Wait()
+
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(calls).To(Equal(1))
+		Expect(dl.List()).To(ConsistOf(HaveField("Error", "webhook returned 503 Service Unavailable")))
+	})
+
+	It("does not retry a rejected delivery", func() {
+		statuses = []int{http.StatusGone}
+		dl := NewMemoryDeadLetters()
+
+		d := dispatcher(dl)
+		d.Notify(ctx, UploadEvent(session))
+		d.Wait()
+
+		Expect(calls).To(Equal(1))
+		Expect(dl.List()).To(ConsistOf(HaveField("Attempts", 1)))
+	})
+
+	It("writes dead letters to a directory", func() {
+		statuses = []int{http.StatusBadRequest}
+		dir := GinkgoT().TempDir()
+		dl, err := NewFileDeadLetters(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		d := dispatcher(dl)
+		ev := UploadEvent(session)
+		d.Notify(ctx, ev)
+		d.Wait()
+
+		files, err := filepath.Glob(filepath.Join(dir, "*-"+ev.ID+".json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+
+		bs, err := os.ReadFile(files[0])
+		Expect(err).NotTo(HaveOccurred())
+		var letter DeadLetter
+		Expect(json.Unmarshal(bs, &letter)).To(Succeed())
+		Expect(letter.Event.Targets).To(ConsistOf(target))
+	})
+})
+
+var _ = Describe("ReplicationEvent", func() {
+	It("maps verifier states to event types", func() {
+		replica := v1.ReplicaStatus{TargetRef: v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "b", Key: "k"}, Exists: true}
+
+		ev := ReplicationEvent(v1.ReplicationStatus{VerificationID: "j1", State: v1.ReplicationComplete, Replicas: []v1.ReplicaStatus{replica}})
+		Expect(ev.Type).To(Equal(v1.EventReplicationComplete))
+		Expect(ev.UploadID).To(Equal("j1"))
+		Expect(ev.Targets).To(ConsistOf(replica.TargetRef))
+
+		ev = ReplicationEvent(v1.ReplicationStatus{VerificationID: "j1", State: v1.ReplicationPartial})
+		Expect(ev.Type).To(Equal(v1.EventReplicationFailed))
+	})
+
+	It("derives event IDs from the upload, type and attempt", func() {
+		st := v1.ReplicationStatus{VerificationID: "j1", State: v1.ReplicationComplete, Attempts: 2}
+		ev := ReplicationEvent(st)
+		Expect(ev.ID).To(HaveLen(32))
+		Expect(ReplicationEvent(st).ID).To(Equal(ev.ID))
+
+		st.Attempts = 3
+		retried := ReplicationEvent(st).ID
+		Expect(retried).NotTo(Equal(ev.ID))
+		st.State = v1.ReplicationMissing
+		Expect(ReplicationEvent(st).ID).NotTo(Equal(retried))
+
+		s := v1.UploadSession{ID: "j1", State: v1.UploadCommitted}
+		Expect(UploadEvent(s).ID).To(Equal(UploadEvent(s).ID))
+		Expect(UploadEvent(s).ID).NotTo(Equal(ev.ID))
+	})
+})
+
+var _ = Describe("VerifySignature", func() {
+	body := []byte(`{"id":"e1"}`)
+
+	headers := func(secret string, ts time.Time) http.Header {
+		unix := strconv.FormatInt(ts.Unix(), 10)
+		return http.Header{
+			HeaderTimestamp: {unix},
+			HeaderSignature: {Sign(secret, unix, body)},
+		}
+	}
+
+	DescribeTable("VerifySignature",
+		func(h http.Header, payload []byte, expectErr string) {
+			err := VerifySignature(secret, h, payload, 5*time.Minute)
+			if expectErr == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(ErrInvalidSignature))
+			Expect(err).To(MatchError(ContainSubstring(expectErr)))
+		},
+
+		Entry("valid", headers(secret, time.Now()), body, ""),
+		Entry("wrong secret", headers("other", time.Now()), body, "invalid webhook signature"),
+		Entry("tampered body", headers(secret, time.Now()), []byte(`{"id":"e2"}`), "invalid webhook signature"),
+		Entry("stale", headers(secret, time.Now().Add(-time.Hour)), body, "timestamp outside tolerance"),
+		Entry("missing timestamp", http.Header{}, body, "bad timestamp"),
+	)
+})
+
+var _ = Describe("LoadEndpoints", func() {
+	DescribeTable("LoadEndpoints",
+		func(body, expectErr string) {
+			path := filepath.Join(GinkgoT().TempDir(), "webhooks.json")
+			Expect(os.WriteFile(path, []byte(body), 0o600)).To(Succeed())
+
+			eps, err := LoadEndpoints(path)
+			if expectErr != "" {
+				Expect(err).To(MatchError(ContainSubstring(expectErr)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(eps).To(HaveLen(1))
+		},
+
+		Entry("valid", `[{"url":"https://hooks.example.com/bsync","secret":"x","events":["upload.failed"]}]`, ""),
+		Entry("no secret", `[{"url":"https://hooks.example.com/bsync"}]`, "has no secret"),
+		Entry("bad scheme", `[{"url":"ftp://hooks.example.com","secret":"x"}]`, "must be http or https"),
+		Entry("not json", `[`, "failed to parse webhooks"),
+	)
+})
@@ -2,10 +2,21 @@ package presign
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/samber/lo"
 
 	v1 "github.com/jordanharrington/bsync/api/v1"
@@ -13,10 +24,40 @@ import (
 
 type s3PresignAPI interface {
 	PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignHeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignDeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// s3ListAPI lists a bucket and HEADs the objects whose ETag may be an MD5.
+type s3ListAPI interface {
+	s3.ListObjectsV2APIClient
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// s3StorageClasses maps portable tiers onto S3 storage classes.
+var s3StorageClasses = map[v1.StorageClass]types.StorageClass{
+	v1.StorageHot:     types.StorageClassStandard,
+	v1.StorageCool:    types.StorageClassStandardIa,
+	v1.StorageCold:    types.StorageClassGlacierIr,
+	v1.StorageArchive: types.StorageClassGlacier,
+}
+
+var s3LockModes = map[v1.RetentionMode]types.ObjectLockMode{
+	v1.RetentionGovernance: types.ObjectLockModeGovernance,
+	v1.RetentionCompliance: types.ObjectLockModeCompliance,
 }
 
+// s3VersionHeader is the response header carrying the version ID S3 assigns to a write.
+const s3VersionHeader = "x-amz-version-id"
+
+// s3CopySourceHeader turns a PutObject into a CopyObject. The SDK cannot presign CopyObject directly,
+// so the header is added to a presigned PUT and signed along with it.
+const s3CopySourceHeader = "x-amz-copy-source"
+
 type s3Presigner struct {
 	signer s3PresignAPI
+	lister s3ListAPI
 }
 
 func NewS3Presigner(ctx context.Context) (Presigner, error) {
@@ -25,7 +66,7 @@ func NewS3Presigner(ctx context.Context) (Presigner, error) {
 		return nil, err
 	}
 	client := s3.NewFromConfig(cfg)
-	return &s3Presigner{signer: s3.NewPresignClient(client)}, nil
+	return &s3Presigner{signer: s3.NewPresignClient(client), lister: client}, nil
 }
 
 func (p *s3Presigner) PresignPut(ctx context.Context, bucket, key string, opts PutOptions) (*v1.PresignedUrl, error) {
@@ -46,30 +87,319 @@ func (p *s3Presigner) PresignPut(ctx context.Context, bucket, key string, opts P
 		}
 	}
 
+	if opts.StorageClass != "" {
+		sc, ok := s3StorageClasses[opts.StorageClass]
+		if !ok {
+			return nil, fmt.Errorf("unsupported storage class %s", opts.StorageClass)
+		}
+		in.StorageClass = sc
+	}
+
+	if opts.IfNoneMatch != "" {
+		in.IfNoneMatch = lo.ToPtr(opts.IfNoneMatch)
+	}
+	if opts.IfMatch != "" {
+		in.IfMatch = lo.ToPtr(opts.IfMatch)
+	}
+
+	// S3 rejects Object Lock writes without an integrity header, so the declared Content-MD5 is signed
+	// into the request and the client must send it.
+	if r := opts.Retention; r != nil {
+		if opts.ContentMD5 == "" {
+			return nil, errors.New("retention requires a content md5")
+		}
+		in.ContentMD5 = lo.ToPtr(opts.ContentMD5)
+		if r.Mode != "" {
+			mode, ok := s3LockModes[r.Mode]
+			if !ok {
+				return nil, fmt.Errorf("unsupported retention mode %s", r.Mode)
+			}
+			in.ObjectLockMode = mode
+			in.ObjectLockRetainUntilDate = r.RetainUntil
+		}
+		if r.LegalHold {
+			in.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+		}
+	}
+
+	if len(opts.Tags) > 0 {
+		in.Tagging = lo.ToPtr(encodeTags(opts.Tags))
+	}
+
 	in.Metadata = make(map[string]string, len(opts.Metadata))
 	for k, v := range opts.Metadata {
 		in.Metadata[k] = v
 	}
 
+	issued := time.Now()
 	out, err := p.signer.PresignPutObject(ctx, in, s3.WithPresignExpires(opts.TTL))
 	if err != nil {
 		return nil, err
 	}
 
-	flat := make(map[string]string, len(out.SignedHeader))
-	for k, vals := range out.SignedHeader {
-		if len(vals) > 0 {
-			flat[k] = vals[0]
+	u := toPresignedUrl(out, s3Ref(bucket, key, ""), issued.Add(opts.TTL))
+	u.VersionHeader = s3VersionHeader
+	return u, nil
+}
+
+func (p *s3Presigner) PresignGet(ctx context.Context, bucket, key string, opts GetOptions) (*v1.PresignedUrl, error) {
+	in := &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}
+	if opts.VersionID != "" {
+		in.VersionId = lo.ToPtr(opts.VersionID)
+	}
+	if opts.Range != "" {
+		in.Range = lo.ToPtr(opts.Range)
+	}
+	if opts.Response.ContentDisposition != "" {
+		in.ResponseContentDisposition = lo.ToPtr(opts.Response.ContentDisposition)
+	}
+	if opts.Response.ContentType != "" {
+		in.ResponseContentType = lo.ToPtr(opts.Response.ContentType)
+	}
+	if opts.Response.CacheControl != "" {
+		in.ResponseCacheControl = lo.ToPtr(opts.Response.CacheControl)
+	}
+	if opts.Response.ContentLanguage != "" {
+		in.ResponseContentLanguage = lo.ToPtr(opts.Response.ContentLanguage)
+	}
+
+	issued := time.Now()
+	out, err := p.signer.PresignGetObject(ctx, in, s3.WithPresignExpires(opts.TTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return toPresignedUrl(out, s3Ref(bucket, key, opts.VersionID), issued.Add(opts.TTL)), nil
+}
+
+func (p *s3Presigner) PresignHead(ctx context.Context, bucket, key string, opts HeadOptions) (*v1.PresignedUrl, error) {
+	in := &s3.HeadObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}
+	if opts.VersionID != "" {
+		in.VersionId = lo.ToPtr(opts.VersionID)
+	}
+
+	issued := time.Now()
+	out, err := p.signer.PresignHeadObject(ctx, in, s3.WithPresignExpires(opts.TTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return toPresignedUrl(out, s3Ref(bucket, key, opts.VersionID), issued.Add(opts.TTL)), nil
+}
+
+func (p *s3Presigner) PresignDelete(ctx context.Context, bucket, key string, opts DeleteOptions) (*v1.PresignedUrl, error) {
+	in := &s3.DeleteObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}
+	if opts.VersionID != "" {
+		in.VersionId = lo.ToPtr(opts.VersionID)
+	}
+
+	issued := time.Now()
+	out, err := p.signer.PresignDeleteObject(ctx, in, s3.WithPresignExpires(opts.TTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return toPresignedUrl(out, s3Ref(bucket, key, opts.VersionID), issued.Add(opts.TTL)), nil
+}
+
+// PresignCopy signs a single-request CopyObject, which S3 limits to sources of up to 5 GiB. Unless opts
+// replaces them, the destination takes its metadata, content type and tags from the source.
+func (p *s3Presigner) PresignCopy(ctx context.Context, bucket, key string, opts CopyOptions) (*v1.PresignedUrl, error) {
+	if opts.Source.Provider != v1.ProviderAWS {
+		return nil, fmt.Errorf("cannot copy from %s to aws", opts.Source.Provider)
+	}
+
+	in := &s3.PutObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+		ACL:    types.ObjectCannedACLPrivate,
+	}
+
+	if opts.Encryption != nil {
+		switch opts.Encryption.Type {
+		case v1.EncProviderManaged:
+			in.ServerSideEncryption = types.ServerSideEncryptionAes256
+		case v1.EncCustomerManaged:
+			in.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+			in.SSEKMSKeyId = lo.ToPtr(opts.Encryption.KeyRef)
+		}
+	}
+
+	if opts.StorageClass != "" {
+		sc, ok := s3StorageClasses[opts.StorageClass]
+		if !ok {
+			return nil, fmt.Errorf("unsupported storage class %s", opts.StorageClass)
+		}
+		in.StorageClass = sc
+	}
+
+	if r := opts.Retention; r != nil {
+		if r.Mode != "" {
+			mode, ok := s3LockModes[r.Mode]
+			if !ok {
+				return nil, fmt.Errorf("unsupported retention mode %s", r.Mode)
+			}
+			in.ObjectLockMode = mode
+			in.ObjectLockRetainUntilDate = r.RetainUntil
+		}
+		if r.LegalHold {
+			in.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+		}
+	}
+
+	headers := map[string]string{s3CopySourceHeader: s3CopySource(opts.Source)}
+	if opts.ContentType != "" || opts.Metadata != nil {
+		headers["x-amz-metadata-directive"] = "REPLACE"
+		if opts.ContentType != "" {
+			in.ContentType = &opts.ContentType
+		}
+		in.Metadata = opts.Metadata
+	}
+	if opts.Tags != nil {
+		headers["x-amz-tagging-directive"] = "REPLACE"
+		in.Tagging = lo.ToPtr(encodeTags(opts.Tags))
+	}
+
+	withSource := func(po *s3.PresignOptions) {
+		po.ClientOptions = append(po.ClientOptions, func(o *s3.Options) {
+			for k, v := range headers {
+				o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue(k, v))
+			}
+		})
+	}
+
+	issued := time.Now()
+	out, err := p.signer.PresignPutObject(ctx, in, s3.WithPresignExpires(opts.TTL), withSource)
+	if err != nil {
+		return nil, err
+	}
+
+	u := toPresignedUrl(out, s3Ref(bucket, key, ""), issued.Add(opts.TTL))
+	u.VersionHeader = s3VersionHeader
+	return u, nil
+}
+
+// s3CopySource renders ref as the URL-encoded bucket/key[?versionId=] value of x-amz-copy-source.
+func s3CopySource(ref v1.TargetRef) string {
+	src := (&url.URL{Path: ref.Bucket + "/" + ref.Key}).EscapedPath()
+	if ref.VersionID != "" {
+		src += "?versionId=" + url.QueryEscape(ref.VersionID)
+	}
+	return src
+}
+
+// List pages through ListObjectsV2. S3 only makes the ETag an MD5 for single-part uploads without SSE-KMS
+// or SSE-C, which a listing cannot tell apart, so every plain 32-digit hex ETag is confirmed with a HEAD
+// before it is reported as the MD5.
+func (p *s3Presigner) List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error {
+	pages := s3.NewListObjectsV2Paginator(p.lister, &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: &prefix,
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Contents {
+			info := ObjectInfo{
+				Key:          lo.FromPtr(obj.Key),
+				Size:         lo.FromPtr(obj.Size),
+				ETag:         strings.Trim(lo.FromPtr(obj.ETag), `"`),
+				LastModified: lo.FromPtr(obj.LastModified),
+			}
+			if sum, err := hex.DecodeString(info.ETag); err == nil && len(sum) == 16 {
+				plain, err := p.plainETag(ctx, bucket, info.Key)
+				if err != nil {
+					return err
+				}
+				if plain {
+					info.MD5 = base64.StdEncoding.EncodeToString(sum)
+				}
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
+
+// plainETag reports whether the object is encrypted in a way that leaves its ETag the MD5 of its content.
+// An object deleted since it was listed is reported as not plain.
+func (p *s3Presigner) plainETag(ctx context.Context, bucket, key string) (bool, error) {
+	out, err := p.lister.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &key})
+	var nf *types.NotFound
+	if errors.As(err, &nf) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to head %s: %v", key, err)
+	}
+
+	switch out.ServerSideEncryption {
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+		return false, nil
+	}
+	return out.SSECustomerAlgorithm == nil, nil
+}
+
+func s3Ref(bucket, key, versionID string) v1.TargetRef {
+	return v1.TargetRef{
+		Provider:  v1.ProviderAWS,
+		Bucket:    bucket,
+		Key:       key,
+		VersionID: versionID,
+	}
+}
+
+// encodeTags renders tags as the URL query string S3 expects in x-amz-tagging.
+func encodeTags(tags map[string]string) string {
+	q := make(url.Values, len(tags))
+	for k, v := range tags {
+		q.Set(k, v)
+	}
+	return q.Encode()
+}
+
+// toPresignedUrl converts an SDK presign result. Every header S3 returns in SignedHeader is part of
+// the signature and must be sent by the client.
+func toPresignedUrl(out *v4.PresignedHTTPRequest, ref v1.TargetRef, expires time.Time) *v1.PresignedUrl {
+	signed := make([]string, 0, len(out.SignedHeader))
+	for k := range out.SignedHeader {
+		signed = append(signed, k)
+	}
+	sort.Strings(signed)
 
 	return &v1.PresignedUrl{
-		TargetRef: v1.TargetRef{
-			Provider: v1.ProviderAWS,
-			Bucket:   bucket,
-			Key:      key,
-		},
-		URL:     out.URL,
-		Headers: flat,
-	}, nil
+		TargetRef:     ref,
+		URL:           out.URL,
+		Method:        out.Method,
+		ExpiresAt:     expires.UTC().Truncate(time.Second),
+		Headers:       flattenSignedHeader(out.SignedHeader),
+		HeaderValues:  out.SignedHeader.Clone(),
+		SignedHeaders: signed,
+	}
+}
+
+// flattenSignedHeader keeps the first value of each signed header for the deprecated
+// v1.PresignedUrl.Headers field.
+func flattenSignedHeader(h http.Header) map[string]string {
+	flat := make(map[string]string, len(h))
+	for k, vals := range h {
+		if len(vals) > 0 {
+			flat[k] = vals[0]
+		}
+	}
+	return flat
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	v1 "github.com/jordanharrington/bsync/api/v1"
@@ -11,6 +12,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/mock"
 	"net/http"
+	"sort"
 	"testing"
 	"time"
 )
@@ -34,6 +36,56 @@ func (m *mockS3PresignAPI) PresignPutObject(
 	return args.Get(0).(*v4.PresignedHTTPRequest), args.Error(1)
 }
 
+func (m *mockS3PresignAPI) PresignGetObject(
+	ctx context.Context,
+	in *s3.GetObjectInput,
+	optFns ...func(*s3.PresignOptions),
+) (*v4.PresignedHTTPRequest, error) {
+	args := m.Called(ctx, in, optFns)
+
+	return args.Get(0).(*v4.PresignedHTTPRequest), args.Error(1)
+}
+
+func (m *mockS3PresignAPI) PresignHeadObject(
+	ctx context.Context,
+	in *s3.HeadObjectInput,
+	optFns ...func(*s3.PresignOptions),
+) (*v4.PresignedHTTPRequest, error) {
+	args := m.Called(ctx, in, optFns)
+
+	return args.Get(0).(*v4.PresignedHTTPRequest), args.Error(1)
+}
+
+func (m *mockS3PresignAPI) PresignDeleteObject(
+	ctx context.Context,
+	in *s3.DeleteObjectInput,
+	optFns ...func(*s3.PresignOptions),
+) (*v4.PresignedHTTPRequest, error) {
+	args := m.Called(ctx, in, optFns)
+
+	return args.Get(0).(*v4.PresignedHTTPRequest), args.Error(1)
+}
+
+type mockS3ListAPI struct {
+	mock.Mock
+}
+
+func (m *mockS3ListAPI) ListObjectsV2(
+	ctx context.Context,
+	in *s3.ListObjectsV2Input,
+	optFns ...func(*s3.Options),
+) (*s3.ListObjectsV2Output, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*s3.ListObjectsV2Output), args.Error(1)
+}
+
+func (m *mockS3ListAPI) HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*s3.HeadObjectOutput), args.Error(1)
+}
+
 var _ = Describe("S3", func() {
 	var (
 		ctx context.Context
@@ -55,14 +107,27 @@ var _ = Describe("S3", func() {
 		meta        map[string]string
 		ttl         time.Duration
 		enc         *v1.EncryptionSpec
+		sc          v1.StorageClass
+		tags        map[string]string
+		ifNoneMatch string
+		ifMatch     string
+		retention   *v1.RetentionSpec
+		contentMD5  string
 		mockURL     string
 		mockHeaders http.Header
 		mockErr     error
 		wantErr     bool
 		wantURL     string
 		wantHdrPick map[string]string
+		wantHdrVals map[string][]string
 		wantSSE     types.ServerSideEncryption
 		wantKMS     *string
+		wantSC      types.StorageClass
+		wantTagging *string
+		wantINM     *string
+		wantIM      *string
+		wantLock    types.ObjectLockMode
+		wantHold    types.ObjectLockLegalHoldStatus
 		wantCalls   int
 	}
 
@@ -78,6 +143,7 @@ var _ = Describe("S3", func() {
 				}
 				retResp = &v4.PresignedHTTPRequest{
 					URL:          tc.mockURL,
+					Method:       http.MethodPut,
 					SignedHeader: h,
 				}
 			}
@@ -112,6 +178,19 @@ var _ = Describe("S3", func() {
 						Expect(*in.SSEKMSKeyId).To(Equal(*tc.wantKMS))
 					}
 
+					Expect(in.StorageClass).To(Equal(tc.wantSC))
+					Expect(in.Tagging).To(Equal(tc.wantTagging))
+					Expect(in.IfNoneMatch).To(Equal(tc.wantINM))
+					Expect(in.IfMatch).To(Equal(tc.wantIM))
+					Expect(in.ObjectLockMode).To(Equal(tc.wantLock))
+					Expect(in.ObjectLockLegalHoldStatus).To(Equal(tc.wantHold))
+					if tc.retention != nil {
+						Expect(in.ObjectLockRetainUntilDate).To(Equal(tc.retention.RetainUntil))
+						Expect(in.ContentMD5).To(Equal(aws.String(tc.contentMD5)))
+					} else {
+						Expect(in.ContentMD5).To(BeNil())
+					}
+
 					// TTL propagation check
 					optFns, _ := args.Get(2).([]func(*s3.PresignOptions))
 					var po s3.PresignOptions
@@ -128,8 +207,15 @@ var _ = Describe("S3", func() {
 				WithMetadata(tc.meta),
 				WithTTL(tc.ttl),
 				WithEncryption(tc.enc),
+				WithStorageClass(tc.sc),
+				WithTags(tc.tags),
+				WithIfNoneMatch(tc.ifNoneMatch),
+				WithIfMatch(tc.ifMatch),
+				WithRetention(tc.retention),
+				WithContentMD5(tc.contentMD5),
 			)
 
+			before := time.Now().UTC()
 			u, err := ps.PresignPut(ctx, tc.bucket, tc.key, opts)
 
 			if tc.wantErr {
@@ -142,10 +228,23 @@ var _ = Describe("S3", func() {
 				Expect(u.TargetRef.Provider).To(Equal(v1.ProviderAWS))
 				Expect(u.TargetRef.Bucket).To(Equal(tc.bucket))
 				Expect(u.TargetRef.Key).To(Equal(tc.key))
+				Expect(u.Method).To(Equal(http.MethodPut))
+				Expect(u.VersionHeader).To(Equal("x-amz-version-id"))
+				Expect(u.ExpiresAt).To(BeTemporally("~", before.Add(tc.ttl), time.Second))
+
+				signed := make([]string, 0, len(tc.mockHeaders))
+				for k := range tc.mockHeaders {
+					signed = append(signed, k)
+				}
+				Expect(u.SignedHeaders).To(ConsistOf(signed))
+				Expect(sort.StringsAreSorted(u.SignedHeaders)).To(BeTrue())
 
 				for k, v := range tc.wantHdrPick {
 					Expect(u.Headers).To(HaveKeyWithValue(k, v))
 				}
+				for k, v := range tc.wantHdrVals {
+					Expect(u.HeaderValues).To(HaveKeyWithValue(k, v))
+				}
 			}
 
 			m.AssertNumberOfCalls(GinkgoT(), "PresignPutObject", tc.wantCalls)
@@ -220,7 +319,7 @@ var _ = Describe("S3", func() {
 			}(),
 		),
 
-		Entry("success: flattens multi-value headers (first wins)",
+		Entry("success: flattens multi-value headers (first wins) and preserves all values",
 			putTestCase{
 				bucket:  "b4",
 				key:     "k4",
@@ -236,12 +335,96 @@ var _ = Describe("S3", func() {
 				wantErr:     false,
 				wantURL:     "https://signed/put?multi=1",
 				wantHdrPick: map[string]string{"X-Custom": "first", "Content-Type": "text/plain"},
+				wantHdrVals: map[string][]string{"X-Custom": {"first", "second"}, "Content-Type": {"text/plain"}},
 				wantSSE:     "",
 				wantKMS:     nil,
 				wantCalls:   1,
 			},
 		),
 
+		Entry("success: archive tier -> GLACIER storage class",
+			putTestCase{
+				bucket:      "b6",
+				key:         "k6",
+				ct:          "application/octet-stream",
+				ttl:         time.Minute,
+				sc:          v1.StorageArchive,
+				mockURL:     "https://signed/put?archive=1",
+				mockHeaders: http.Header{"X-Amz-Storage-Class": {"GLACIER"}},
+				wantURL:     "https://signed/put?archive=1",
+				wantHdrPick: map[string]string{"X-Amz-Storage-Class": "GLACIER"},
+				wantSC:      types.StorageClassGlacier,
+				wantCalls:   1,
+			},
+		),
+
+		Entry("success: tags -> x-amz-tagging",
+			putTestCase{
+				bucket:      "b7",
+				key:         "k7",
+				ct:          "text/plain",
+				ttl:         time.Minute,
+				tags:        map[string]string{"retention": "90d", "team": "data eng"},
+				mockURL:     "https://signed/put?tags=1",
+				mockHeaders: http.Header{"X-Amz-Tagging": {"retention=90d&team=data+eng"}},
+				wantURL:     "https://signed/put?tags=1",
+				wantHdrPick: map[string]string{"X-Amz-Tagging": "retention=90d&team=data+eng"},
+				wantTagging: aws.String("retention=90d&team=data+eng"),
+				wantCalls:   1,
+			},
+		),
+
+		Entry("success: if_none_match -> create-only conditional write",
+			putTestCase{
+				bucket:      "b8",
+				key:         "k8",
+				ct:          "text/plain",
+				ttl:         time.Minute,
+				ifNoneMatch: "*",
+				mockURL:     "https://signed/put?inm=1",
+				mockHeaders: http.Header{"If-None-Match": {"*"}},
+				wantURL:     "https://signed/put?inm=1",
+				wantHdrPick: map[string]string{"If-None-Match": "*"},
+				wantINM:     aws.String("*"),
+				wantCalls:   1,
+			},
+		),
+
+		Entry("success: if_match -> overwrite only the expected version",
+			putTestCase{
+				bucket:      "b9",
+				key:         "k9",
+				ct:          "text/plain",
+				ttl:         time.Minute,
+				ifMatch:     `"9b2cf535f27731c974343645a3985328"`,
+				mockURL:     "https://signed/put?im=1",
+				mockHeaders: http.Header{"If-Match": {`"9b2cf535f27731c974343645a3985328"`}},
+				wantURL:     "https://signed/put?im=1",
+				wantIM:      aws.String(`"9b2cf535f27731c974343645a3985328"`),
+				wantCalls:   1,
+			},
+		),
+
+		Entry("success: compliance retention with legal hold -> Object Lock",
+			func() putTestCase {
+				until := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+				return putTestCase{
+					bucket:      "b10",
+					key:         "k10",
+					ct:          "application/octet-stream",
+					ttl:         time.Minute,
+					retention:   &v1.RetentionSpec{Mode: v1.RetentionCompliance, RetainUntil: &until, LegalHold: true},
+					contentMD5:  "1B2M2Y8AsgTpgAmY7PhCfg==",
+					mockURL:     "https://signed/put?lock=1",
+					mockHeaders: http.Header{"X-Amz-Object-Lock-Mode": {"COMPLIANCE"}, "Content-Md5": {"1B2M2Y8AsgTpgAmY7PhCfg=="}},
+					wantURL:     "https://signed/put?lock=1",
+					wantLock:    types.ObjectLockModeCompliance,
+					wantHold:    types.ObjectLockLegalHoldStatusOn,
+					wantCalls:   1,
+				}
+			}(),
+		),
+
 		Entry("error: AWS SDK presign failure bubbles up",
 			putTestCase{
 				bucket:    "b5",
@@ -258,4 +441,320 @@ var _ = Describe("S3", func() {
 			},
 		),
 	)
+
+	It("refuses retention without a content md5", func() {
+		until := time.Now().Add(time.Hour)
+		opts := NewPutOptions(WithRetention(&v1.RetentionSpec{Mode: v1.RetentionGovernance, RetainUntil: &until}))
+		_, err := ps.PresignPut(ctx, "b", "k", opts)
+		Expect(err).To(MatchError("retention requires a content md5"))
+		m.AssertNotCalled(GinkgoT(), "PresignPutObject", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	type getTestCase struct {
+		bucket    string
+		key       string
+		rng       string
+		ttl       time.Duration
+		mockURL   string
+		resp      v1.ResponseOverrides
+		version   string
+		mockErr   error
+		wantErr   bool
+		wantRange *string
+		wantRCD   *string
+		wantRCT   *string
+		wantRCC   *string
+		wantRCL   *string
+		wantVer   *string
+	}
+
+	DescribeTable("PresignGet",
+		func(tc getTestCase) {
+			var retResp *v4.PresignedHTTPRequest
+			if tc.mockErr == nil {
+				retResp = &v4.PresignedHTTPRequest{
+					URL:          tc.mockURL,
+					Method:       http.MethodGet,
+					SignedHeader: http.Header{"Host": {"b.s3.amazonaws.com"}},
+				}
+			}
+			m.
+				On("PresignGetObject", mock.Anything, mock.AnythingOfType("*s3.GetObjectInput"), mock.Anything).
+				Run(func(args mock.Arguments) {
+					in := args.Get(1).(*s3.GetObjectInput)
+					Expect(*in.Bucket).To(Equal(tc.bucket))
+					Expect(*in.Key).To(Equal(tc.key))
+					Expect(in.Range).To(Equal(tc.wantRange))
+					Expect(in.ResponseContentDisposition).To(Equal(tc.wantRCD))
+					Expect(in.ResponseContentType).To(Equal(tc.wantRCT))
+					Expect(in.ResponseCacheControl).To(Equal(tc.wantRCC))
+					Expect(in.ResponseContentLanguage).To(Equal(tc.wantRCL))
+					Expect(in.VersionId).To(Equal(tc.wantVer))
+
+					optFns, _ := args.Get(2).([]func(*s3.PresignOptions))
+					var po s3.PresignOptions
+					for _, fn := range optFns {
+						fn(&po)
+					}
+					Expect(po.Expires).To(Equal(tc.ttl))
+				}).
+				Return(retResp, tc.mockErr).
+				Once()
+
+			u, err := ps.PresignGet(ctx, tc.bucket, tc.key, NewGetOptions(WithGetTTL(tc.ttl), WithRange(tc.rng), WithResponseOverrides(tc.resp), WithVersion(tc.version)))
+
+			if tc.wantErr {
+				Expect(err).To(HaveOccurred())
+				Expect(u).To(BeNil())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(u.URL).To(Equal(tc.mockURL))
+				Expect(u.TargetRef).To(Equal(v1.TargetRef{Provider: v1.ProviderAWS, Bucket: tc.bucket, Key: tc.key, VersionID: tc.version}))
+				Expect(u.Headers).To(HaveKeyWithValue("Host", "b.s3.amazonaws.com"))
+				Expect(u.Method).To(Equal(http.MethodGet))
+				Expect(u.SignedHeaders).To(Equal([]string{"Host"}))
+			}
+
+			m.AssertExpectations(GinkgoT())
+		},
+
+		Entry("success: whole object", getTestCase{
+			bucket:  "b1",
+			key:     "k1",
+			ttl:     time.Minute,
+			mockURL: "https://signed/get?ok=1",
+		}),
+
+		Entry("success: ranged read is bound into the request", getTestCase{
+			bucket:    "b2",
+			key:       "k2",
+			rng:       "bytes=0-511",
+			ttl:       30 * time.Second,
+			mockURL:   "https://signed/get?range=1",
+			wantRange: aws.String("bytes=0-511"),
+		}),
+
+		Entry("success: response header overrides", getTestCase{
+			bucket:  "b4",
+			key:     "k4",
+			ttl:     time.Minute,
+			mockURL: "https://signed/get?overrides=1",
+			resp: v1.ResponseOverrides{
+				ContentDisposition: `attachment; filename="report.pdf"`,
+				ContentType:        "application/pdf",
+				CacheControl:       "no-store",
+				ContentLanguage:    "en-US",
+			},
+			wantRCD: aws.String(`attachment; filename="report.pdf"`),
+			wantRCT: aws.String("application/pdf"),
+			wantRCC: aws.String("no-store"),
+			wantRCL: aws.String("en-US"),
+		}),
+
+		Entry("success: specific version", getTestCase{
+			bucket:  "b5",
+			key:     "k5",
+			ttl:     time.Minute,
+			version: "3HL4kqtJlcpXroDTDmJ+rmSpXd3dIbrHY",
+			mockURL: "https://signed/get?versionId=1",
+			wantVer: aws.String("3HL4kqtJlcpXroDTDmJ+rmSpXd3dIbrHY"),
+		}),
+
+		Entry("error: AWS SDK presign failure bubbles up", getTestCase{
+			bucket:  "b3",
+			key:     "k3",
+			ttl:     time.Minute,
+			mockErr: http.ErrHandlerTimeout,
+			wantErr: true,
+		}),
+	)
+
+	type deleteTestCase struct {
+		bucket  string
+		key     string
+		version string
+		mockErr error
+		wantErr bool
+		wantVer *string
+	}
+
+	DescribeTable("PresignDelete",
+		func(tc deleteTestCase) {
+			var retResp *v4.PresignedHTTPRequest
+			if tc.mockErr == nil {
+				retResp = &v4.PresignedHTTPRequest{
+					URL:          "https://signed/delete",
+					Method:       http.MethodDelete,
+					SignedHeader: http.Header{"Host": {"b.s3.amazonaws.com"}},
+				}
+			}
+			m.
+				On("PresignDeleteObject", mock.Anything, mock.AnythingOfType("*s3.DeleteObjectInput"), mock.Anything).
+				Run(func(args mock.Arguments) {
+					in := args.Get(1).(*s3.DeleteObjectInput)
+					Expect(*in.Bucket).To(Equal(tc.bucket))
+					Expect(*in.Key).To(Equal(tc.key))
+					Expect(in.VersionId).To(Equal(tc.wantVer))
+				}).
+				Return(retResp, tc.mockErr).
+				Once()
+
+			u, err := ps.PresignDelete(ctx, tc.bucket, tc.key, NewDeleteOptions(WithDeleteVersion(tc.version)))
+
+			if tc.wantErr {
+				Expect(err).To(HaveOccurred())
+				Expect(u).To(BeNil())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(u.Method).To(Equal(http.MethodDelete))
+				Expect(u.TargetRef).To(Equal(v1.TargetRef{Provider: v1.ProviderAWS, Bucket: tc.bucket, Key: tc.key, VersionID: tc.version}))
+			}
+
+			m.AssertExpectations(GinkgoT())
+		},
+
+		Entry("success: current object", deleteTestCase{
+			bucket: "b1",
+			key:    "k1",
+		}),
+
+		Entry("success: specific version", deleteTestCase{
+			bucket:  "b2",
+			key:     "k2",
+			version: "null",
+			wantVer: aws.String("null"),
+		}),
+
+		Entry("error: AWS SDK presign failure bubbles up", deleteTestCase{
+			bucket:  "b3",
+			key:     "k3",
+			mockErr: http.ErrHandlerTimeout,
+			wantErr: true,
+		}),
+	)
+
+	It("PresignHead signs a HEAD for a specific version", func() {
+		m.
+			On("PresignHeadObject", mock.Anything, mock.AnythingOfType("*s3.HeadObjectInput"), mock.Anything).
+			Run(func(args mock.Arguments) {
+				in := args.Get(1).(*s3.HeadObjectInput)
+				Expect(*in.Bucket).To(Equal("b1"))
+				Expect(*in.Key).To(Equal("k1"))
+				Expect(in.VersionId).To(Equal(aws.String("v1")))
+			}).
+			Return(&v4.PresignedHTTPRequest{
+				URL:          "https://signed/head",
+				Method:       http.MethodHead,
+				SignedHeader: http.Header{"Host": {"b1.s3.amazonaws.com"}},
+			}, nil).
+			Once()
+
+		u, err := ps.PresignHead(ctx, "b1", "k1", NewHeadOptions(WithHeadVersion("v1")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(u.Method).To(Equal(http.MethodHead))
+		Expect(u.TargetRef.VersionID).To(Equal("v1"))
+		m.AssertExpectations(GinkgoT())
+	})
+
+	It("PresignCopy signs x-amz-copy-source with the destination encryption", func() {
+		ps := &s3Presigner{signer: s3.NewPresignClient(s3.New(s3.Options{
+			Region:      "us-east-1",
+			Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		}))}
+
+		opts := NewCopyOptions(
+			WithCopySource(v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "src", Key: "dir/a b.txt", VersionID: "v1"}),
+			WithCopyEncryption(&v1.EncryptionSpec{Type: v1.EncProviderManaged}),
+		)
+		u, err := ps.PresignCopy(ctx, "dst", "dir/a b.txt", opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(u.Method).To(Equal(http.MethodPut))
+		Expect(u.HeaderValues).To(HaveKeyWithValue("X-Amz-Copy-Source", []string{"src/dir/a%20b.txt?versionId=v1"}))
+		Expect(u.HeaderValues).To(HaveKeyWithValue("X-Amz-Server-Side-Encryption", []string{"AES256"}))
+		Expect(u.URL).To(ContainSubstring("x-amz-copy-source"))
+		Expect(u.VersionHeader).To(Equal("x-amz-version-id"))
+	})
+
+	It("PresignCopy replaces the destination's class, type, metadata and tags when given", func() {
+		ps := &s3Presigner{signer: s3.NewPresignClient(s3.New(s3.Options{
+			Region:      "us-east-1",
+			Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		}))}
+
+		opts := NewCopyOptions(
+			WithCopySource(v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "src", Key: "k"}),
+			WithCopyStorageClass(v1.StorageArchive),
+			WithCopyContentType("image/png"),
+			WithCopyMetadata(map[string]string{"owner": "a"}),
+			WithCopyTags(map[string]string{"tier": "cold"}),
+		)
+		u, err := ps.PresignCopy(ctx, "dst", "k", opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(u.HeaderValues).To(HaveKeyWithValue("X-Amz-Storage-Class", []string{"GLACIER"}))
+		Expect(u.HeaderValues).To(HaveKeyWithValue("X-Amz-Metadata-Directive", []string{"REPLACE"}))
+		Expect(u.URL).To(ContainSubstring("X-Amz-Tagging-Directive=REPLACE"))
+		Expect(u.HeaderValues).To(HaveKeyWithValue("X-Amz-Tagging", []string{"tier=cold"}))
+		Expect(u.HeaderValues).To(HaveKeyWithValue("X-Amz-Meta-Owner", []string{"a"}))
+	})
+
+	It("PresignCopy rejects a source on another provider", func() {
+		opts := NewCopyOptions(WithCopySource(v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "src", Key: "k"}))
+		_, err := ps.PresignCopy(ctx, "dst", "k", opts)
+		Expect(err).To(MatchError(ContainSubstring("cannot copy from gcp to aws")))
+		m.AssertNotCalled(GinkgoT(), "PresignPutObject", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	It("List pages through the bucket and reports MD5 ETags", func() {
+		l := &mockS3ListAPI{}
+		head := func(key string) any {
+			return mock.MatchedBy(func(in *s3.HeadObjectInput) bool { return *in.Key == key })
+		}
+		l.
+			On("HeadObject", mock.Anything, head("photos/a")).
+			Return(&s3.HeadObjectOutput{ServerSideEncryption: types.ServerSideEncryptionAes256}, nil).
+			Once()
+		l.
+			On("HeadObject", mock.Anything, head("photos/c")).
+			Return(&s3.HeadObjectOutput{ServerSideEncryption: types.ServerSideEncryptionAwsKms}, nil).
+			Once()
+		l.
+			On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *s3.ListObjectsV2Input) bool {
+				return *in.Prefix == "photos/" && in.ContinuationToken == nil
+			})).
+			Return(&s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("photos/a"), Size: aws.Int64(4), ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`)},
+				},
+				IsTruncated:           aws.Bool(true),
+				NextContinuationToken: aws.String("t1"),
+			}, nil).
+			Once()
+		l.
+			On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *s3.ListObjectsV2Input) bool {
+				return aws.ToString(in.ContinuationToken) == "t1"
+			})).
+			Return(&s3.ListObjectsV2Output{
+				Contents: []types.Object{
+					{Key: aws.String("photos/b"), Size: aws.Int64(9), ETag: aws.String(`"9b2cf535f27731c974343645a3985328-2"`)},
+					{Key: aws.String("photos/c"), Size: aws.Int64(4), ETag: aws.String(`"4d186321c1a7f0f354b297e8914ab240"`)},
+				},
+				IsTruncated: aws.Bool(false),
+			}, nil).
+			Once()
+
+		var got []ObjectInfo
+		lister := &s3Presigner{signer: m, lister: l}
+		Expect(lister.List(ctx, "b1", "photos/", func(o ObjectInfo) error {
+			got = append(got, o)
+			return nil
+		})).To(Succeed())
+
+		Expect(got).To(HaveLen(3))
+		Expect(got[0].MD5).To(Equal("1B2M2Y8AsgTpgAmY7PhCfg=="))
+		Expect(got[1].ETag).To(Equal("9b2cf535f27731c974343645a3985328-2"))
+		Expect(got[1].MD5).To(BeEmpty())
+		Expect(got[2].MD5).To(BeEmpty(), "an SSE-KMS ETag is not an MD5")
+		l.AssertNumberOfCalls(GinkgoT(), "HeadObject", 2)
+		l.AssertExpectations(GinkgoT())
+	})
 })
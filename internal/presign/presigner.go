@@ -8,10 +8,22 @@ import (
 )
 
 type PutOptions struct {
-	ContentType string
-	Metadata    map[string]string
-	TTL         time.Duration
-	Encryption  *v1.EncryptionSpec
+	ContentType  string
+	Metadata     map[string]string
+	TTL          time.Duration
+	Encryption   *v1.EncryptionSpec
+	StorageClass v1.StorageClass
+	Tags         map[string]string
+	// IfNoneMatch "*" makes the write fail if the object already exists.
+	IfNoneMatch string
+	// IfMatch makes the write fail unless the current object has this ETag.
+	IfMatch string
+	// Retention maps to S3 Object Lock: Mode to the lock mode, RetainUntil to the retain-until date and
+	// LegalHold to the legal hold status. S3 only accepts Object Lock writes that carry ContentMD5.
+	Retention *v1.RetentionSpec
+	// ContentMD5 is the base64 MD5 the client declared. It is signed into writes with Retention, so the
+	// client must send the same Content-MD5 header.
+	ContentMD5 string
 }
 
 // PutOption mutates a PutOptions.
@@ -50,8 +62,239 @@ func WithEncryption(enc *v1.EncryptionSpec) PutOption {
 	return func(o *PutOptions) { o.Encryption = enc }
 }
 
+// WithStorageClass sets the storage tier (empty means the bucket default).
+func WithStorageClass(sc v1.StorageClass) PutOption {
+	return func(o *PutOptions) { o.StorageClass = sc }
+}
+
+// WithTags sets the object tags bound into the signed request (nil = none).
+func WithTags(tags map[string]string) PutOption {
+	return func(o *PutOptions) { o.Tags = tags }
+}
+
+// WithIfNoneMatch binds an If-None-Match precondition ("*" = create only).
+func WithIfNoneMatch(v string) PutOption {
+	return func(o *PutOptions) { o.IfNoneMatch = v }
+}
+
+// WithIfMatch binds an If-Match precondition on the current ETag.
+func WithIfMatch(etag string) PutOption {
+	return func(o *PutOptions) { o.IfMatch = etag }
+}
+
+// WithRetention sets the WORM retention and legal hold applied to the object (nil = none).
+func WithRetention(r *v1.RetentionSpec) PutOption {
+	return func(o *PutOptions) { o.Retention = r }
+}
+
+// WithContentMD5 sets the base64 MD5 the body must have (empty means none).
+func WithContentMD5(sum string) PutOption {
+	return func(o *PutOptions) { o.ContentMD5 = sum }
+}
+
+type GetOptions struct {
+	TTL time.Duration
+	// Range is an HTTP byte range such as "bytes=0-511" (empty means the whole object).
+	Range string
+	// Response overrides headers on the provider's response (S3 response-*, Azure rscd/rsct/...,
+	// GCS response-content-*).
+	Response v1.ResponseOverrides
+	// VersionID selects a historical version (S3 version ID, Azure versionId, GCS generation).
+	VersionID string
+}
+
+// GetOption mutates a GetOptions.
+type GetOption func(*GetOptions)
+
+// NewGetOptions applies options over sensible defaults.
+func NewGetOptions(opts ...GetOption) GetOptions {
+	o := GetOptions{
+		TTL: 5 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithGetTTL sets the presign TTL for a GET.
+func WithGetTTL(d time.Duration) GetOption {
+	return func(o *GetOptions) { o.TTL = d }
+}
+
+// WithRange restricts the GET to an HTTP byte range.
+func WithRange(r string) GetOption {
+	return func(o *GetOptions) { o.Range = r }
+}
+
+// WithResponseOverrides sets the response headers the provider should return.
+func WithResponseOverrides(r v1.ResponseOverrides) GetOption {
+	return func(o *GetOptions) { o.Response = r }
+}
+
+// WithVersion selects a specific object version.
+func WithVersion(id string) GetOption {
+	return func(o *GetOptions) { o.VersionID = id }
+}
+
+type HeadOptions struct {
+	TTL time.Duration
+	// VersionID selects a historical version.
+	VersionID string
+}
+
+// HeadOption mutates a HeadOptions.
+type HeadOption func(*HeadOptions)
+
+// NewHeadOptions applies options over sensible defaults.
+func NewHeadOptions(opts ...HeadOption) HeadOptions {
+	o := HeadOptions{
+		TTL: 5 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithHeadTTL sets the presign TTL for a HEAD.
+func WithHeadTTL(d time.Duration) HeadOption {
+	return func(o *HeadOptions) { o.TTL = d }
+}
+
+// WithHeadVersion selects a specific object version.
+func WithHeadVersion(id string) HeadOption {
+	return func(o *HeadOptions) { o.VersionID = id }
+}
+
+type DeleteOptions struct {
+	TTL time.Duration
+	// VersionID deletes a specific version instead of the current object.
+	VersionID string
+}
+
+// DeleteOption mutates a DeleteOptions.
+type DeleteOption func(*DeleteOptions)
+
+// NewDeleteOptions applies options over sensible defaults.
+func NewDeleteOptions(opts ...DeleteOption) DeleteOptions {
+	o := DeleteOptions{
+		TTL: 5 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithDeleteTTL sets the presign TTL for a DELETE.
+func WithDeleteTTL(d time.Duration) DeleteOption {
+	return func(o *DeleteOptions) { o.TTL = d }
+}
+
+// WithDeleteVersion deletes a specific object version.
+func WithDeleteVersion(id string) DeleteOption {
+	return func(o *DeleteOptions) { o.VersionID = id }
+}
+
+type CopyOptions struct {
+	TTL time.Duration
+	// Source is the object the provider copies from. It must live on the same provider as the destination.
+	Source v1.TargetRef
+	// Encryption applies to the destination object.
+	Encryption *v1.EncryptionSpec
+	// StorageClass and Retention apply to the destination object.
+	StorageClass v1.StorageClass
+	Retention    *v1.RetentionSpec
+	// ContentType, Metadata and Tags replace the source's when set; otherwise the destination keeps them.
+	ContentType string
+	Metadata    map[string]string
+	Tags        map[string]string
+}
+
+// CopyOption mutates a CopyOptions.
+type CopyOption func(*CopyOptions)
+
+// NewCopyOptions applies options over sensible defaults.
+func NewCopyOptions(opts ...CopyOption) CopyOptions {
+	o := CopyOptions{
+		TTL: 5 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCopyTTL sets the presign TTL for a copy.
+func WithCopyTTL(d time.Duration) CopyOption {
+	return func(o *CopyOptions) { o.TTL = d }
+}
+
+// WithCopySource sets the object to copy from, including an optional version.
+func WithCopySource(src v1.TargetRef) CopyOption {
+	return func(o *CopyOptions) { o.Source = src }
+}
+
+// WithCopyEncryption sets the encryption of the destination object.
+func WithCopyEncryption(e *v1.EncryptionSpec) CopyOption {
+	return func(o *CopyOptions) { o.Encryption = e }
+}
+
+// WithCopyStorageClass sets the storage class of the destination object.
+func WithCopyStorageClass(sc v1.StorageClass) CopyOption {
+	return func(o *CopyOptions) { o.StorageClass = sc }
+}
+
+// WithCopyRetention sets the WORM retention of the destination object.
+func WithCopyRetention(r *v1.RetentionSpec) CopyOption {
+	return func(o *CopyOptions) { o.Retention = r }
+}
+
+// WithCopyContentType replaces the source's content type on the destination object.
+func WithCopyContentType(ct string) CopyOption {
+	return func(o *CopyOptions) { o.ContentType = ct }
+}
+
+// WithCopyMetadata replaces the source's metadata on the destination object.
+func WithCopyMetadata(md map[string]string) CopyOption {
+	return func(o *CopyOptions) { o.Metadata = md }
+}
+
+// WithCopyTags replaces the source's tags on the destination object.
+func WithCopyTags(tags map[string]string) CopyOption {
+	return func(o *CopyOptions) { o.Tags = tags }
+}
+
 type Presigner interface {
 	PresignPut(ctx context.Context, bucket, key string, opts PutOptions) (*v1.PresignedUrl, error)
+	PresignGet(ctx context.Context, bucket, key string, opts GetOptions) (*v1.PresignedUrl, error)
+	PresignHead(ctx context.Context, bucket, key string, opts HeadOptions) (*v1.PresignedUrl, error)
+	PresignDelete(ctx context.Context, bucket, key string, opts DeleteOptions) (*v1.PresignedUrl, error)
+	// PresignCopy signs a server-side copy of opts.Source into bucket/key, so the payload never
+	// passes through the client.
+	PresignCopy(ctx context.Context, bucket, key string, opts CopyOptions) (*v1.PresignedUrl, error)
+}
+
+// ObjectInfo describes a listed object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	// MD5 is the base64 MD5 digest of the content, when the provider's listing reveals it.
+	MD5 string
+}
+
+// Lister is implemented by presigners that can also enumerate a bucket. Listing is not presigned: it
+// runs with the gateway's own credentials.
+type Lister interface {
+	// List calls fn for every object under prefix, in key order, until fn returns an error.
+	List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error
 }
 
 type Registry map[v1.Provider]Presigner
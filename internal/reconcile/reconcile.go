@@ -0,0 +1,400 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+)
+
+// Location is one bucket a replication policy keeps in sync. Repaired replicas are written with
+// Encryption, which should match what the gateway's encryption policy requires for the bucket.
+type Location struct {
+	Provider   v1.Provider        `json:"provider"`
+	Bucket     string             `json:"bucket"`
+	Encryption *v1.EncryptionSpec `json:"encryption,omitempty"`
+}
+
+func (l Location) ref(key string) v1.TargetRef {
+	return v1.TargetRef{Provider: l.Provider, Bucket: l.Bucket, Key: key, Encryption: l.Encryption}
+}
+
+// Policy declares that every object under Prefix should exist, identically, in each of Targets.
+type Policy struct {
+	Prefix  string     `json:"prefix"`
+	Targets []Location `json:"targets"`
+}
+
+// LoadPolicy reads a JSON policy from path.
+func LoadPolicy(path string) (Policy, error) {
+	var p Policy
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("failed to parse policy: %v", err)
+	}
+	if len(p.Targets) < 2 {
+		return p, fmt.Errorf("policy needs at least two targets, got %d", len(p.Targets))
+	}
+	for _, t := range p.Targets {
+		enc := t.Encryption
+		if enc == nil {
+			continue
+		}
+		switch {
+		case enc.Type == v1.EncProviderManaged && enc.KeyRef == "":
+		case enc.Type == v1.EncCustomerManaged && enc.KeyRef != "":
+		default:
+			return p, fmt.Errorf("invalid encryption for %s/%s. must be provider_managed, or customer_managed with a key_ref",
+				t.Provider, t.Bucket)
+		}
+	}
+	return p, nil
+}
+
+type ActionKind string
+
+const (
+	// ActionCopy repairs a replica with a server-side copy within one provider.
+	ActionCopy ActionKind = "copy"
+	// ActionReupload streams the source through the reconciler into a replica on another provider.
+	ActionReupload ActionKind = "reupload"
+)
+
+// Action repairs one replica from the source of its key. Error is set when an applied action failed.
+type Action struct {
+	Kind        ActionKind   `json:"kind"`
+	Source      v1.TargetRef `json:"source"`
+	Destination v1.TargetRef `json:"destination"`
+	Applied     bool         `json:"applied,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// Drift describes a key whose replicas disagree. Source is the replica the others are repaired from: the
+// most recently modified one. Conflict is set when existing replicas differ, in which case the newest is
+// not necessarily the correct one.
+type Drift struct {
+	Key        string     `json:"key"`
+	Source     Location   `json:"source"`
+	Missing    []Location `json:"missing,omitempty"`
+	Mismatched []Location `json:"mismatched,omitempty"`
+	Reasons    []string   `json:"reasons,omitempty"`
+	Conflict   bool       `json:"conflict,omitempty"`
+}
+
+// ScanReport is the outcome of a scan and, in apply mode, of the repairs it planned.
+type ScanReport struct {
+	Policy    Policy    `json:"policy"`
+	Scanned   int       `json:"scanned"`
+	Drift     []Drift   `json:"drift"`
+	Actions   []Action  `json:"actions"`
+	Failed    int       `json:"failed"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Reconciler lists every target of a policy, diffs the listings by key, size and checksum, and repairs
+// drifted replicas. It never deletes: an object present in only some targets is copied to the rest.
+// Conflicting keys are only reported unless conflict repair is enabled.
+type Reconciler struct {
+	signers         presign.Registry
+	client          *http.Client
+	ttl             time.Duration
+	repairConflicts bool
+}
+
+// Option mutates a Reconciler.
+type Option func(*Reconciler)
+
+// WithHTTPClient sets the client used for repair requests.
+func WithHTTPClient(c *http.Client) Option {
+	return func(r *Reconciler) { r.client = c }
+}
+
+// WithConflictRepair makes conflicting keys repaired like any other drift: every replica is overwritten
+// with the most recently modified one.
+func WithConflictRepair() Option {
+	return func(r *Reconciler) { r.repairConflicts = true }
+}
+
+func NewReconciler(signers presign.Registry, opts ...Option) *Reconciler {
+	r := &Reconciler{
+		signers: signers,
+		client:  http.DefaultClient,
+		ttl:     15 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Scan lists every target of p and returns the drift it found together with the actions that would
+// repair it. Scan does not change any replica.
+func (r *Reconciler) Scan(ctx context.Context, p Policy) (*ScanReport, error) {
+	listings := make([]map[string]presign.ObjectInfo, len(p.Targets))
+	keys := map[string]struct{}{}
+	for i, loc := range p.Targets {
+		listing, err := r.list(ctx, loc, p.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s/%s: %w", loc.Provider, loc.Bucket, err)
+		}
+		for k := range listing {
+			keys[k] = struct{}{}
+		}
+		listings[i] = listing
+	}
+
+	rep := &ScanReport{
+		Policy:    p,
+		Scanned:   len(keys),
+		Drift:     []Drift{},
+		Actions:   []Action{},
+		CheckedAt: time.Now().UTC(),
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		d, ok := diff(key, p.Targets, listings)
+		if !ok {
+			continue
+		}
+		rep.Drift = append(rep.Drift, d)
+		if d.Conflict && !r.repairConflicts {
+			continue
+		}
+
+		for _, dst := range append(append([]Location{}, d.Missing...), d.Mismatched...) {
+			kind := ActionReupload
+			if dst.Provider == d.Source.Provider {
+				kind = ActionCopy
+			}
+			rep.Actions = append(rep.Actions, Action{
+				Kind:        kind,
+				Source:      d.Source.ref(key),
+				Destination: dst.ref(key),
+			})
+		}
+	}
+	return rep, nil
+}
+
+// Apply runs every action of rep and records its outcome in place. A failed action does not stop the
+// rest; rep.Failed counts them.
+func (r *Reconciler) Apply(ctx context.Context, rep *ScanReport) {
+	for i := range rep.Actions {
+		a := &rep.Actions[i]
+
+		var err error
+		switch a.Kind {
+		case ActionCopy:
+			err = r.copy(ctx, a.Source, a.Destination)
+		case ActionReupload:
+			err = r.reupload(ctx, a.Source, a.Destination)
+		default:
+			err = fmt.Errorf("unknown action: %s", a.Kind)
+		}
+
+		a.Applied = err == nil
+		if err != nil {
+			a.Error = err.Error()
+			rep.Failed++
+		}
+	}
+}
+
+// Repair writes src over dst: a server-side copy when both are on the same provider, otherwise a GET from
+// src streamed into a PUT to dst. dst is written with its own encryption spec.
+func (r *Reconciler) Repair(ctx context.Context, src, dst v1.TargetRef) error {
+	if src.Provider == dst.Provider {
+		return r.copy(ctx, src, dst)
+	}
+	return r.reupload(ctx, src, dst)
+}
+
+func (r *Reconciler) list(ctx context.Context, loc Location, prefix string) (map[string]presign.ObjectInfo, error) {
+	presigner, ok := r.signers[loc.Provider]
+	if !ok {
+		return nil, fmt.Errorf("provider not configured: %s", loc.Provider)
+	}
+	lister, ok := presigner.(presign.Lister)
+	if !ok {
+		return nil, fmt.Errorf("listing not supported for %s", loc.Provider)
+	}
+
+	out := map[string]presign.ObjectInfo{}
+	err := lister.List(ctx, loc.Bucket, prefix, func(o presign.ObjectInfo) error {
+		out[o.Key] = o
+		return nil
+	})
+	return out, err
+}
+
+// diff compares the replicas of key against the most recently modified one. Checksums are only compared
+// when both listings report an MD5; a multipart or KMS ETag says nothing about the content.
+func diff(key string, targets []Location, listings []map[string]presign.ObjectInfo) (Drift, bool) {
+	d := Drift{Key: key}
+
+	src := -1
+	for i, listing := range listings {
+		o, ok := listing[key]
+		if ok && (src < 0 || o.LastModified.After(listings[src][key].LastModified)) {
+			src = i
+		}
+	}
+	d.Source = targets[src]
+	want := listings[src][key]
+
+	for i, listing := range listings {
+		if i == src {
+			continue
+		}
+
+		o, ok := listing[key]
+		switch {
+		case !ok:
+			d.Missing = append(d.Missing, targets[i])
+		case o.Size != want.Size:
+			d.Mismatched = append(d.Mismatched, targets[i])
+			d.Reasons = append(d.Reasons, fmt.Sprintf("%s/%s: size %d, expected %d",
+				targets[i].Provider, targets[i].Bucket, o.Size, want.Size))
+		case o.MD5 != "" && want.MD5 != "" && o.MD5 != want.MD5:
+			d.Mismatched = append(d.Mismatched, targets[i])
+			d.Reasons = append(d.Reasons, fmt.Sprintf("%s/%s: md5 %s, expected %s",
+				targets[i].Provider, targets[i].Bucket, o.MD5, want.MD5))
+		}
+	}
+	d.Conflict = len(d.Mismatched) > 0
+	return d, len(d.Missing) > 0 || len(d.Mismatched) > 0
+}
+
+func (r *Reconciler) copy(ctx context.Context, src, dst v1.TargetRef) error {
+	presigner, ok := r.signers[dst.Provider]
+	if !ok {
+		return fmt.Errorf("provider not configured: %s", dst.Provider)
+	}
+
+	copyOpts := []presign.CopyOption{
+		presign.WithCopyTTL(r.ttl),
+		presign.WithCopySource(src),
+		presign.WithCopyEncryption(dst.Encryption),
+	}
+	if o := dst.Options; o != nil {
+		copyOpts = append(copyOpts,
+			presign.WithCopyStorageClass(o.StorageClass),
+			presign.WithCopyRetention(o.Retention),
+			presign.WithCopyContentType(o.ContentType),
+			presign.WithCopyMetadata(o.Metadata),
+			presign.WithCopyTags(o.Tags),
+		)
+	}
+	opts := presign.NewCopyOptions(copyOpts...)
+	u, err := presigner.PresignCopy(ctx, dst.Bucket, dst.Key, opts)
+	if err != nil {
+		return fmt.Errorf("presign failed for %s: %w", dst.Provider, err)
+	}
+	return r.do(ctx, u, nil, 0)
+}
+
+func (r *Reconciler) reupload(ctx context.Context, src, dst v1.TargetRef) error {
+	from, ok := r.signers[src.Provider]
+	if !ok {
+		return fmt.Errorf("provider not configured: %s", src.Provider)
+	}
+	to, ok := r.signers[dst.Provider]
+	if !ok {
+		return fmt.Errorf("provider not configured: %s", dst.Provider)
+	}
+
+	get, err := from.PresignGet(ctx, src.Bucket, src.Key, presign.NewGetOptions(
+		presign.WithGetTTL(r.ttl),
+		presign.WithVersion(src.VersionID),
+	))
+	if err != nil {
+		return fmt.Errorf("presign failed for %s: %w", src.Provider, err)
+	}
+	req, err := newRequest(ctx, get, nil)
+	if err != nil {
+		return err
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("get failed: %s", res.Status)
+	}
+
+	putOpts := []presign.PutOption{
+		presign.WithTTL(r.ttl),
+		presign.WithContentType(res.Header.Get("Content-Type")),
+		presign.WithEncryption(dst.Encryption),
+	}
+	if o := dst.Options; o != nil {
+		if o.ContentType != "" {
+			putOpts = append(putOpts, presign.WithContentType(o.ContentType))
+		}
+		putOpts = append(putOpts,
+			presign.WithMetadata(o.Metadata),
+			presign.WithStorageClass(o.StorageClass),
+			presign.WithTags(o.Tags),
+			presign.WithRetention(o.Retention),
+		)
+	}
+	put, err := to.PresignPut(ctx, dst.Bucket, dst.Key, presign.NewPutOptions(putOpts...))
+	if err != nil {
+		return fmt.Errorf("presign failed for %s: %w", dst.Provider, err)
+	}
+	return r.do(ctx, put, res.Body, res.ContentLength)
+}
+
+func (r *Reconciler) do(ctx context.Context, u *v1.PresignedUrl, body io.Reader, size int64) error {
+	req, err := newRequest(ctx, u, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("%s failed: %s", u.Method, res.Status)
+	}
+	return nil
+}
+
+func newRequest(ctx context.Context, u *v1.PresignedUrl, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, u.Method, u.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range u.HeaderValues {
+		for _, val := range vals {
+			req.Header.Add(k, val)
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,369 @@
+package reconcile
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestReconcile(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Reconcile")
+}
+
+type object struct {
+	body        string
+	contentType string
+	modified    time.Time
+}
+
+// objectStore is a local stand-in for every provider: objects are keyed by /provider/bucket/key.
+type objectStore struct {
+	mu      sync.Mutex
+	objects map[string]object
+	now     time.Time
+}
+
+func (s *objectStore) put(path string, o object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[path] = o
+}
+
+func (s *objectStore) get(path string) (object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[path]
+	return o, ok
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		o, ok := s.get(r.URL.Path)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", o.contentType)
+		_, _ = io.WriteString(w, o.body)
+	case http.MethodPut:
+		if src := r.Header.Get("X-Copy-Source"); src != "" {
+			o, ok := s.get(src)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if ct := r.Header.Get("Content-Type"); ct != "" {
+				o.contentType = ct
+			}
+			o.modified = s.now
+			s.put(r.URL.Path, o)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		s.put(r.URL.Path, object{body: string(b), contentType: r.Header.Get("Content-Type"), modified: s.now})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// storePresigner signs plain URLs on the stand-in and lists it directly.
+type storePresigner struct {
+	presign.Presigner
+	provider v1.Provider
+	base     string
+	store    *objectStore
+}
+
+func (p storePresigner) url(bucket, key, method string) *v1.PresignedUrl {
+	return &v1.PresignedUrl{URL: p.base + "/" + string(p.provider) + "/" + bucket + "/" + key, Method: method}
+}
+
+func (p storePresigner) PresignGet(_ context.Context, bucket, key string, _ presign.GetOptions) (*v1.PresignedUrl, error) {
+	return p.url(bucket, key, http.MethodGet), nil
+}
+
+func (p storePresigner) PresignPut(_ context.Context, bucket, key string, opts presign.PutOptions) (*v1.PresignedUrl, error) {
+	u := p.url(bucket, key, http.MethodPut)
+	u.HeaderValues = map[string][]string{"Content-Type": {opts.ContentType}}
+	return u, nil
+}
+
+func (p storePresigner) PresignCopy(_ context.Context, bucket, key string, opts presign.CopyOptions) (*v1.PresignedUrl, error) {
+	u := p.url(bucket, key, http.MethodPut)
+	src := opts.Source
+	u.HeaderValues = map[string][]string{"X-Copy-Source": {"/" + string(src.Provider) + "/" + src.Bucket + "/" + src.Key}}
+	if opts.ContentType != "" {
+		u.HeaderValues["Content-Type"] = []string{opts.ContentType}
+	}
+	return u, nil
+}
+
+func (p storePresigner) List(_ context.Context, bucket, prefix string, fn func(presign.ObjectInfo) error) error {
+	base := "/" + string(p.provider) + "/" + bucket + "/"
+
+	p.store.mu.Lock()
+	var infos []presign.ObjectInfo
+	for path, o := range p.store.objects {
+		key, ok := strings.CutPrefix(path, base)
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		sum := md5.Sum([]byte(o.body))
+		infos = append(infos, presign.ObjectInfo{
+			Key:          key,
+			Size:         int64(len(o.body)),
+			MD5:          base64.StdEncoding.EncodeToString(sum[:]),
+			LastModified: o.modified,
+		})
+	}
+	p.store.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ = Describe("Reconciler", func() {
+	var (
+		ctx     context.Context
+		store   *objectStore
+		srv     *httptest.Server
+		signers presign.Registry
+		policy  Policy
+		t0      time.Time
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		t0 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		store = &objectStore{objects: map[string]object{}, now: t0.Add(time.Hour)}
+		srv = httptest.NewServer(store)
+		DeferCleanup(srv.Close)
+
+		signers = presign.Registry{}
+		for _, p := range []v1.Provider{v1.ProviderAWS, v1.ProviderGCP} {
+			signers[p] = storePresigner{provider: p, base: srv.URL, store: store}
+		}
+
+		policy = Policy{
+			Prefix: "photos/",
+			Targets: []Location{
+				{Provider: v1.ProviderAWS, Bucket: "primary"},
+				{Provider: v1.ProviderAWS, Bucket: "backup"},
+				{Provider: v1.ProviderGCP, Bucket: "mirror"},
+			},
+		}
+	})
+
+	seed := func(path, body string, modified time.Time) {
+		store.put(path, object{body: body, contentType: "image/png", modified: modified})
+	}
+
+	It("reports nothing when every replica agrees", func() {
+		for _, p := range []string{"/aws/primary/photos/a", "/aws/backup/photos/a", "/gcp/mirror/photos/a"} {
+			seed(p, "same", t0)
+		}
+		seed("/aws/primary/other/x", "ignored", t0)
+
+		rep, err := NewReconciler(signers).Scan(ctx, policy)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Scanned).To(Equal(1))
+		Expect(rep.Drift).To(BeEmpty())
+		Expect(rep.Actions).To(BeEmpty())
+	})
+
+	It("plans copies within a provider and re-uploads across providers", func() {
+		seed("/aws/primary/photos/a", "new content", t0.Add(time.Minute))
+		seed("/aws/backup/photos/a", "old", t0)
+		seed("/gcp/mirror/photos/b", "only here", t0)
+
+		rep, err := NewReconciler(signers, WithConflictRepair()).Scan(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(rep.Drift).To(HaveLen(2))
+		Expect(rep.Drift[0].Key).To(Equal("photos/a"))
+		Expect(rep.Drift[0].Source).To(Equal(policy.Targets[0]))
+		Expect(rep.Drift[0].Missing).To(ConsistOf(policy.Targets[2]))
+		Expect(rep.Drift[0].Mismatched).To(ConsistOf(policy.Targets[1]))
+		Expect(rep.Drift[0].Reasons).To(ConsistOf("aws/backup: size 3, expected 11"))
+		Expect(rep.Drift[1].Source).To(Equal(policy.Targets[2]))
+
+		Expect(rep.Actions).To(ConsistOf(
+			Action{Kind: ActionReupload, Source: policy.Targets[0].ref("photos/a"), Destination: policy.Targets[2].ref("photos/a")},
+			Action{Kind: ActionCopy, Source: policy.Targets[0].ref("photos/a"), Destination: policy.Targets[1].ref("photos/a")},
+			Action{Kind: ActionReupload, Source: policy.Targets[2].ref("photos/b"), Destination: policy.Targets[0].ref("photos/b")},
+			Action{Kind: ActionReupload, Source: policy.Targets[2].ref("photos/b"), Destination: policy.Targets[1].ref("photos/b")},
+		))
+
+		_, ok := store.get("/gcp/mirror/photos/a")
+		Expect(ok).To(BeFalse(), "a scan must not repair anything")
+	})
+
+	It("reports conflicting replicas without planning repairs for them", func() {
+		seed("/aws/primary/photos/a", "corrupted late write", t0.Add(time.Minute))
+		seed("/aws/backup/photos/a", "good", t0)
+		seed("/gcp/mirror/photos/b", "only here", t0)
+
+		rep, err := NewReconciler(signers).Scan(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(rep.Drift).To(HaveLen(2))
+		Expect(rep.Drift[0].Conflict).To(BeTrue())
+		Expect(rep.Drift[1].Conflict).To(BeFalse())
+		Expect(rep.Actions).To(HaveLen(2))
+		Expect(rep.Actions).To(HaveEach(HaveField("Source.Key", "photos/b")))
+	})
+
+	It("writes repaired replicas with their location's encryption", func() {
+		enc := &v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: "arn:aws:kms:us-east-1:111122223333:key/backup"}
+		policy.Targets[1].Encryption = enc
+		seed("/aws/primary/photos/a", "content", t0)
+
+		rep, err := NewReconciler(signers).Scan(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(rep.Actions).To(ContainElement(HaveField("Destination", v1.TargetRef{
+			Provider: v1.ProviderAWS, Bucket: "backup", Key: "photos/a", Encryption: enc,
+		})))
+	})
+
+	It("detects checksum drift with equal sizes", func() {
+		seed("/aws/primary/photos/a", "abcd", t0.Add(time.Minute))
+		seed("/aws/backup/photos/a", "abcd", t0)
+		seed("/gcp/mirror/photos/a", "wxyz", t0)
+
+		rep, err := NewReconciler(signers).Scan(ctx, policy)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Drift).To(HaveLen(1))
+		Expect(rep.Drift[0].Mismatched).To(ConsistOf(policy.Targets[2]))
+		Expect(rep.Drift[0].Reasons[0]).To(HavePrefix("gcp/mirror: md5 "))
+	})
+
+	It("repairs drift in apply mode", func() {
+		seed("/aws/primary/photos/a", "new content", t0.Add(time.Minute))
+		seed("/aws/backup/photos/a", "old", t0)
+		seed("/gcp/mirror/photos/b", "only here", t0)
+
+		r := NewReconciler(signers, WithHTTPClient(srv.Client()), WithConflictRepair())
+		rep, err := r.Scan(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+
+		r.Apply(ctx, rep)
+
+		Expect(rep.Failed).To(BeZero())
+		for _, a := range rep.Actions {
+			Expect(a.Applied).To(BeTrue())
+		}
+		for _, p := range []string{"/aws/backup/photos/a", "/gcp/mirror/photos/a"} {
+			o, ok := store.get(p)
+			Expect(ok).To(BeTrue())
+			Expect(o.body).To(Equal("new content"))
+			Expect(o.contentType).To(Equal("image/png"))
+		}
+
+		again, err := r.Scan(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again.Drift).To(BeEmpty())
+	})
+
+	It("records failed actions and carries on", func() {
+		seed("/aws/primary/photos/a", "content", t0)
+
+		rep, err := NewReconciler(signers, WithHTTPClient(srv.Client())).Scan(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+		store.mu.Lock()
+		delete(store.objects, "/aws/primary/photos/a")
+		store.mu.Unlock()
+
+		NewReconciler(signers, WithHTTPClient(srv.Client())).Apply(ctx, rep)
+
+		Expect(rep.Failed).To(Equal(2))
+		Expect(rep.Actions).To(HaveEach(HaveField("Error", ContainSubstring("404"))))
+	})
+
+	It("repairs a single replica from a source", func() {
+		seed("/aws/primary/photos/a", "content", t0)
+		r := NewReconciler(signers, WithHTTPClient(srv.Client()))
+		src := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "primary", Key: "photos/a"}
+
+		Expect(r.Repair(ctx, src, v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "backup", Key: "photos/a"})).To(Succeed())
+		Expect(r.Repair(ctx, src, v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "mirror", Key: "photos/a"})).To(Succeed())
+
+		for _, p := range []string{"/aws/backup/photos/a", "/gcp/mirror/photos/a"} {
+			o, ok := store.get(p)
+			Expect(ok).To(BeTrue())
+			Expect(o.body).To(Equal("content"))
+		}
+	})
+
+	It("repairs with the destination's own options", func() {
+		seed("/aws/primary/photos/a", "content", t0)
+		r := NewReconciler(signers, WithHTTPClient(srv.Client()))
+		src := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "primary", Key: "photos/a"}
+		opts := &v1.TargetOptions{ContentType: "image/webp"}
+
+		Expect(r.Repair(ctx, src, v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "backup", Key: "photos/a", Options: opts})).To(Succeed())
+		Expect(r.Repair(ctx, src, v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "mirror", Key: "photos/a", Options: opts})).To(Succeed())
+
+		for _, p := range []string{"/aws/backup/photos/a", "/gcp/mirror/photos/a"} {
+			o, ok := store.get(p)
+			Expect(ok).To(BeTrue())
+			Expect(o.contentType).To(Equal("image/webp"))
+		}
+	})
+
+	It("fails a scan when a target cannot be listed", func() {
+		signers[v1.ProviderGCP] = struct{ presign.Presigner }{}
+
+		_, err := NewReconciler(signers).Scan(ctx, policy)
+
+		Expect(err).To(MatchError("failed to list gcp/mirror: listing not supported for gcp"))
+	})
+
+	DescribeTable("LoadPolicy",
+		func(body, expectErr string) {
+			path := filepath.Join(GinkgoT().TempDir(), "policy.json")
+			Expect(os.WriteFile(path, []byte(body), 0o600)).To(Succeed())
+
+			p, err := LoadPolicy(path)
+			if expectErr != "" {
+				Expect(err).To(MatchError(ContainSubstring(expectErr)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Targets).To(HaveLen(2))
+		},
+
+		Entry("valid", `{"prefix":"p/","targets":[{"provider":"aws","bucket":"a"},{"provider":"gcp","bucket":"b"}]}`, ""),
+		Entry("one target", `{"targets":[{"provider":"aws","bucket":"a"}]}`, "at least two targets"),
+		Entry("not json", `{`, "failed to parse policy"),
+		Entry("encryption per target",
+			`{"targets":[{"provider":"aws","bucket":"a","encryption":{"type":"customer_managed","key_ref":"k"}},{"provider":"gcp","bucket":"b"}]}`, ""),
+		Entry("customer_managed without key_ref",
+			`{"targets":[{"provider":"aws","bucket":"a","encryption":{"type":"customer_managed"}},{"provider":"gcp","bucket":"b"}]}`,
+			"invalid encryption for aws/a"),
+	)
+})
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+)
+
+// handleCopyObject handles http.MethodPost to /v1/presign/copy
+func (h *handler) handleCopyObject(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var in v1.CopyObjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	src := []v1.TargetRef{in.Source}
+	if err := h.applyKeyPolicy(src); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+	in.Source = src[0]
+
+	if err := h.applyPolicies(in.Destinations); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateCopyRequest(in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	presigner, ok := h.signers[in.Source.Provider]
+	if !ok {
+		http.Error(w, fmt.Sprintf("provider not configured: %s", in.Source.Provider), http.StatusBadRequest)
+		return
+	}
+
+	ttl := time.Duration(in.ExpiresMillis) * time.Millisecond
+	urls := make([]v1.PresignedUrl, 0, len(in.Destinations))
+	for _, d := range in.Destinations {
+		opts := presign.NewCopyOptions(
+			presign.WithCopyTTL(ttl),
+			presign.WithCopySource(in.Source),
+			presign.WithCopyEncryption(d.Encryption),
+		)
+
+		url, err := presigner.PresignCopy(ctx, d.Bucket, d.Key, opts)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("presign failed for %s: %v", d.Provider, err), http.StatusBadGateway)
+			return
+		}
+
+		urls = append(urls, *url)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v1.CopyObjectResponse{
+		Targets: urls,
+	})
+}
+
+// validateCopyRequest checks that every destination can be filled by a server-side copy of the source.
+// Providers only copy within their own storage, so cross-provider repair still needs a client upload.
+func validateCopyRequest(in v1.CopyObjectRequest) error {
+	if err := validateObjectTargets([]v1.TargetRef{in.Source}, in.ExpiresMillis); err != nil {
+		return err
+	}
+
+	if len(in.Destinations) == 0 {
+		return errors.New("at least one destination is required")
+	}
+
+	for _, d := range in.Destinations {
+		if d.Provider != in.Source.Provider {
+			return fmt.Errorf("cannot copy from %s to %s. destinations must use the source provider", in.Source.Provider, d.Provider)
+		}
+		if err := validateTargetName(d); err != nil {
+			return err
+		}
+		if d.VersionID != "" {
+			return errors.New("version_id is not allowed on copy destinations")
+		}
+		if d.Bucket == in.Source.Bucket && d.Key == in.Source.Key && in.Source.VersionID == "" {
+			return fmt.Errorf("cannot copy %s/%s onto itself", d.Bucket, d.Key)
+		}
+		if err := validateEncryption(d.Encryption); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Copy", func() {
+	var (
+		aws *mockPresigner
+		hnd *handler
+	)
+
+	BeforeEach(func() {
+		aws = &mockPresigner{}
+		hnd = &handler{
+			signers: presign.Registry{
+				v1.ProviderAWS: aws,
+			},
+			keys: defaultKeyPolicy(),
+		}
+	})
+
+	type copyTestCase struct {
+		req                v1.CopyObjectRequest
+		mockSetup          func()
+		policy             func(h *handler)
+		expectHTTP         int
+		expectTargets      int
+		expectErrSubstr    string
+		expectPresignCalls int
+	}
+
+	DescribeTable("PresignCopy",
+		func(tc copyTestCase) {
+			if tc.policy != nil {
+				tc.policy(hnd)
+			}
+			if tc.mockSetup != nil {
+				tc.mockSetup()
+			}
+
+			bs, _ := json.Marshal(tc.req)
+			req := httptest.NewRequest(http.MethodPost, "/v1/presign/copy", bytes.NewReader(bs))
+			rr := httptest.NewRecorder()
+			hnd.handleCopyObject(rr, req)
+
+			Expect(rr.Code).To(Equal(tc.expectHTTP), "body: %s", rr.Body.String())
+
+			var resp v1.CopyObjectResponse
+			if rr.Code == http.StatusOK {
+				Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+			}
+			Expect(resp.Targets).To(HaveLen(tc.expectTargets))
+
+			if tc.expectErrSubstr != "" {
+				Expect(rr.Body.String()).To(ContainSubstring(tc.expectErrSubstr))
+			}
+
+			aws.AssertNumberOfCalls(GinkgoT(), "PresignCopy", tc.expectPresignCalls)
+			aws.AssertExpectations(GinkgoT())
+		},
+
+		Entry("success: repairs a replica from a versioned source", copyTestCase{
+			req: v1.CopyObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Source:        v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", VersionID: "v2"},
+				Destinations: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1"},
+				},
+			},
+			mockSetup: func() {
+				optsMatcher := mock.MatchedBy(func(o presign.CopyOptions) bool {
+					return o.Source.Bucket == "bsync-b1" && o.Source.VersionID == "v2" && o.TTL == 2*time.Minute
+				})
+				aws.
+					On("PresignCopy", mock.Anything, "bsync-b2", "k1", optsMatcher).
+					Return(&v1.PresignedUrl{
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1"},
+						URL:       "https://signed/copy",
+					}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("success: destination picks up the default encryption", copyTestCase{
+			req: v1.CopyObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Source:        v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				Destinations: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1"},
+				},
+			},
+			policy: func(h *handler) {
+				h.encryption.defaultFor(v1.ProviderAWS, v1.EncryptionSpec{Type: v1.EncProviderManaged})
+			},
+			mockSetup: func() {
+				optsMatcher := mock.MatchedBy(func(o presign.CopyOptions) bool {
+					return o.Encryption != nil && o.Encryption.Type == v1.EncProviderManaged
+				})
+				aws.
+					On("PresignCopy", mock.Anything, "bsync-b2", "k1", optsMatcher).
+					Return(&v1.PresignedUrl{URL: "https://signed/copy"}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("validation: cross-provider copies are rejected", copyTestCase{
+			req: v1.CopyObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Source:        v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				Destinations: []v1.TargetRef{
+					{Provider: v1.ProviderGCP, Bucket: "bsync-b2", Key: "k1"},
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "cannot copy from aws to gcp",
+		}),
+
+		Entry("validation: copying an object onto itself", copyTestCase{
+			req: v1.CopyObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Source:        v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				Destinations: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "onto itself",
+		}),
+
+		Entry("validation: at least one destination", copyTestCase{
+			req: v1.CopyObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Source:        v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "at least one destination",
+		}),
+
+		Entry("validation: reserved source keys cannot be copied", copyTestCase{
+			req: v1.CopyObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Source:        v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "_internal/state"},
+				Destinations: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "state"},
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "prefix _internal/ is reserved",
+		}),
+	)
+})
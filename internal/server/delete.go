@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+)
+
+// handleDeleteObject handles http.MethodPost to /v1/presign/delete
+func (h *handler) handleDeleteObject(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var in v1.DeleteObjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.applyKeyPolicy(in.Targets); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateDeleteRequest(in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ttl := time.Duration(in.ExpiresMillis) * time.Millisecond
+	urls := make([]v1.PresignedUrl, 0, len(in.Targets))
+	for _, s := range in.Targets {
+		presigner, ok := h.signers[s.Provider]
+		if !ok {
+			http.Error(w, fmt.Sprintf("provider not configured: %s", s.Provider), http.StatusBadRequest)
+			return
+		}
+
+		opts := presign.NewDeleteOptions(
+			presign.WithDeleteTTL(ttl),
+			presign.WithDeleteVersion(s.VersionID),
+		)
+
+		url, err := presigner.PresignDelete(ctx, s.Bucket, s.Key, opts)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("presign failed for %s: %v", s.Provider, err), http.StatusBadGateway)
+			return
+		}
+
+		urls = append(urls, *url)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v1.DeleteObjectResponse{
+		Targets: urls,
+	})
+}
+
+func validateDeleteRequest(in v1.DeleteObjectRequest) error {
+	return validateObjectTargets(in.Targets, in.ExpiresMillis)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+// encryptionPolicy holds the gateway-wide encryption rules applied to every target before it is signed.
+type encryptionPolicy struct {
+	// defaults is injected, per provider, into targets that omit an encryption spec.
+	defaults map[v1.Provider]v1.EncryptionSpec
+	// required lists, per provider, the buckets that only accept writes encrypted with an allowed
+	// customer_managed key.
+	required map[v1.Provider]map[string]bool
+	// allowedKeyRefs restricts customer_managed key_ref values per provider (KMS ARNs/aliases,
+	// Key Vault key URIs, Cloud KMS key names). A provider without an entry accepts any key_ref.
+	allowedKeyRefs map[v1.Provider]map[string]bool
+}
+
+// apply returns the encryption spec that should be signed for t.
+func (p encryptionPolicy) apply(t v1.TargetRef) (*v1.EncryptionSpec, error) {
+	enc := t.Encryption
+	if enc == nil {
+		if def, ok := p.defaults[t.Provider]; ok {
+			enc = &def
+		}
+	}
+
+	required := p.required[t.Provider][t.Bucket]
+	if enc == nil {
+		if required {
+			return nil, fmt.Errorf("encryption required for %s bucket %s", t.Provider, t.Bucket)
+		}
+		return nil, nil
+	}
+	if required && enc.Type != v1.EncCustomerManaged {
+		return nil, fmt.Errorf("customer_managed encryption required for %s bucket %s", t.Provider, t.Bucket)
+	}
+
+	if enc.Type == v1.EncCustomerManaged {
+		// A regulated bucket must be written with one of our keys, so it needs an allowlist to check against.
+		allowed, ok := p.allowedKeyRefs[t.Provider]
+		if required && !ok {
+			return nil, fmt.Errorf("no key_ref allowlist configured for %s bucket %s", t.Provider, t.Bucket)
+		}
+		if ok && !allowed[enc.KeyRef] {
+			return nil, fmt.Errorf("key_ref %s is not allowed for %s", enc.KeyRef, t.Provider)
+		}
+	}
+
+	return enc, nil
+}
+
+func (p *encryptionPolicy) requireFor(provider v1.Provider, buckets ...string) {
+	if p.required == nil {
+		p.required = make(map[v1.Provider]map[string]bool)
+	}
+	if p.required[provider] == nil {
+		p.required[provider] = make(map[string]bool, len(buckets))
+	}
+	for _, b := range buckets {
+		p.required[provider][b] = true
+	}
+}
+
+func (p *encryptionPolicy) allowKeyRefs(provider v1.Provider, refs ...string) {
+	if p.allowedKeyRefs == nil {
+		p.allowedKeyRefs = make(map[v1.Provider]map[string]bool)
+	}
+	if p.allowedKeyRefs[provider] == nil {
+		p.allowedKeyRefs[provider] = make(map[string]bool, len(refs))
+	}
+	for _, r := range refs {
+		p.allowedKeyRefs[provider][r] = true
+	}
+}
+
+func (p *encryptionPolicy) defaultFor(provider v1.Provider, spec v1.EncryptionSpec) {
+	if p.defaults == nil {
+		p.defaults = make(map[v1.Provider]v1.EncryptionSpec)
+	}
+	p.defaults[provider] = spec
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Encryption", func() {
+	const (
+		allowedARN = "arn:aws:kms:us-east-1:111122223333:key/allowed"
+		otherARN   = "arn:aws:kms:us-east-1:111122223333:key/other"
+	)
+
+	newPolicy := func() encryptionPolicy {
+		var p encryptionPolicy
+		p.requireFor(v1.ProviderAWS, "regulated")
+		p.allowKeyRefs(v1.ProviderAWS, allowedARN, "alias/bsync")
+		return p
+	}
+
+	type encTestCase struct {
+		policy          func() encryptionPolicy
+		target          v1.TargetRef
+		expectEnc       *v1.EncryptionSpec
+		expectErrSubstr string
+	}
+
+	DescribeTable("encryptionPolicy.apply",
+		func(tc encTestCase) {
+			enc, err := tc.policy().apply(tc.target)
+			if tc.expectErrSubstr == "" {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(enc).To(Equal(tc.expectEnc))
+			} else {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(tc.expectErrSubstr))
+			}
+		},
+
+		Entry("zero policy leaves targets alone", encTestCase{
+			policy: func() encryptionPolicy { return encryptionPolicy{} },
+			target: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "regulated", Key: "k"},
+		}),
+		Entry("unencrypted write to a regulated bucket", encTestCase{
+			policy:          newPolicy,
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "regulated", Key: "k"},
+			expectErrSubstr: "encryption required for aws bucket regulated",
+		}),
+		Entry("provider_managed write to a regulated bucket", encTestCase{
+			policy: newPolicy,
+			target: v1.TargetRef{
+				Provider: v1.ProviderAWS, Bucket: "regulated", Key: "k",
+				Encryption: &v1.EncryptionSpec{Type: v1.EncProviderManaged},
+			},
+			expectErrSubstr: "customer_managed encryption required for aws bucket regulated",
+		}),
+		Entry("regulated bucket without a key_ref allowlist", encTestCase{
+			policy: func() encryptionPolicy {
+				var p encryptionPolicy
+				p.requireFor(v1.ProviderGCP, "regulated")
+				return p
+			},
+			target: v1.TargetRef{
+				Provider: v1.ProviderGCP, Bucket: "regulated", Key: "k",
+				Encryption: &v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: "projects/p/locations/l/keyRings/r/cryptoKeys/k"},
+			},
+			expectErrSubstr: "no key_ref allowlist configured for gcp bucket regulated",
+		}),
+		Entry("unencrypted write to an unregulated bucket", encTestCase{
+			policy: newPolicy,
+			target: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "scratch", Key: "k"},
+		}),
+		Entry("allowed key_ref", encTestCase{
+			policy: newPolicy,
+			target: v1.TargetRef{
+				Provider: v1.ProviderAWS, Bucket: "regulated", Key: "k",
+				Encryption: &v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: "alias/bsync"},
+			},
+			expectEnc: &v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: "alias/bsync"},
+		}),
+		Entry("key_ref outside the allowlist", encTestCase{
+			policy: newPolicy,
+			target: v1.TargetRef{
+				Provider: v1.ProviderAWS, Bucket: "scratch", Key: "k",
+				Encryption: &v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: otherARN},
+			},
+			expectErrSubstr: "key_ref " + otherARN + " is not allowed for aws",
+		}),
+		Entry("allowlist is per provider", encTestCase{
+			policy: newPolicy,
+			target: v1.TargetRef{
+				Provider: v1.ProviderGCP, Bucket: "scratch", Key: "k",
+				Encryption: &v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: "projects/p/locations/l/keyRings/r/cryptoKeys/k"},
+			},
+			expectEnc: &v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: "projects/p/locations/l/keyRings/r/cryptoKeys/k"},
+		}),
+		Entry("default is injected and satisfies the requirement", encTestCase{
+			policy: func() encryptionPolicy {
+				p := newPolicy()
+				p.defaultFor(v1.ProviderAWS, v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: allowedARN})
+				return p
+			},
+			target:    v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "regulated", Key: "k"},
+			expectEnc: &v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: allowedARN},
+		}),
+		Entry("injected default is still checked against the allowlist", encTestCase{
+			policy: func() encryptionPolicy {
+				p := newPolicy()
+				p.defaultFor(v1.ProviderAWS, v1.EncryptionSpec{Type: v1.EncCustomerManaged, KeyRef: otherARN})
+				return p
+			},
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "regulated", Key: "k"},
+			expectErrSubstr: "is not allowed for aws",
+		}),
+	)
+})
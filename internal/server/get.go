@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+)
+
+var byteRange = regexp.MustCompile(`^bytes=(?:(\d{1,19})-(\d{0,19})|-\d{1,19})$`)
+
+var contentLanguage = regexp.MustCompile(`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*(, *[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*)*$`)
+
+// handleGetObject handles http.MethodPost to /v1/presign/get
+func (h *handler) handleGetObject(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var in v1.GetObjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.applyKeyPolicy(in.Targets); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateGetRequest(in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ttl := time.Duration(in.ExpiresMillis) * time.Millisecond
+	urls := make([]v1.PresignedUrl, 0, len(in.Targets))
+	for _, s := range in.Targets {
+		presigner, ok := h.signers[s.Provider]
+		if !ok {
+			http.Error(w, fmt.Sprintf("provider not configured: %s", s.Provider), http.StatusBadRequest)
+			return
+		}
+
+		opts := presign.NewGetOptions(
+			presign.WithGetTTL(ttl),
+			presign.WithResponseOverrides(in.ResponseOverrides),
+			presign.WithVersion(s.VersionID),
+			presign.WithRange(in.Range),
+		)
+
+		url, err := presigner.PresignGet(ctx, s.Bucket, s.Key, opts)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("presign failed for %s: %v", s.Provider, err), http.StatusBadGateway)
+			return
+		}
+
+		urls = append(urls, *url)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v1.GetObjectResponse{
+		Targets: urls,
+	})
+}
+
+func validateGetRequest(in v1.GetObjectRequest) error {
+	if err := validateObjectTargets(in.Targets, in.ExpiresMillis); err != nil {
+		return err
+	}
+
+	if in.Range != "" {
+		if err := validateRange(in.Range); err != nil {
+			return err
+		}
+	}
+
+	return validateResponseOverrides(in.ResponseOverrides)
+}
+
+// validateRange checks that r is a single byte range whose first byte does not come after its last.
+func validateRange(r string) error {
+	m := byteRange.FindStringSubmatch(r)
+	if m == nil {
+		return fmt.Errorf("invalid range %s. must be a single byte range such as bytes=0-1023", r)
+	}
+	if m[1] == "" || m[2] == "" {
+		return nil
+	}
+
+	first, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid range %s. %v", r, err)
+	}
+	last, err := strconv.ParseUint(m[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid range %s. %v", r, err)
+	}
+	if first > last {
+		return fmt.Errorf("invalid range %s. first byte must not be after the last", r)
+	}
+	return nil
+}
+
+// validateObjectTargets applies the checks shared by every read and delete presign request.
+func validateObjectTargets(targets []v1.TargetRef, expiresMillis int64) error {
+	d := time.Duration(expiresMillis) * time.Millisecond
+	if d < pv.minPresignTTL {
+		return fmt.Errorf("expires_ms too small (min %d ms)", pv.minPresignTTL.Milliseconds())
+	}
+	if d > pv.maxPresignTTL {
+		return fmt.Errorf("expires_ms too large (max %d ms)", pv.maxPresignTTL.Milliseconds())
+	}
+
+	for _, s := range targets {
+		if err := validateTargetName(s); err != nil {
+			return err
+		}
+		if err := validateVersionID(s.Provider, s.VersionID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateResponseOverrides rejects values that would produce malformed or injected response headers.
+func validateResponseOverrides(o v1.ResponseOverrides) error {
+	for name, v := range map[string]string{
+		"response_content_disposition": o.ContentDisposition,
+		"response_content_type":        o.ContentType,
+		"response_cache_control":       o.CacheControl,
+		"response_content_language":    o.ContentLanguage,
+	} {
+		if len(v) > 1024 {
+			return fmt.Errorf("%s too long (max 1024 bytes)", name)
+		}
+		for _, r := range v {
+			if r < 0x20 || r > 0x7e {
+				return fmt.Errorf("invalid %s. must be printable ASCII", name)
+			}
+		}
+	}
+
+	if o.ContentDisposition != "" {
+		disp, _, err := mime.ParseMediaType(o.ContentDisposition)
+		if err != nil || (disp != "inline" && disp != "attachment") {
+			return fmt.Errorf("invalid response_content_disposition %s. must be inline or attachment", o.ContentDisposition)
+		}
+	}
+	if o.ContentType != "" {
+		mt, _, err := mime.ParseMediaType(o.ContentType)
+		if err != nil {
+			return fmt.Errorf("invalid response_content_type %s: %v", o.ContentType, err)
+		}
+		// The provider serves the object with this type from its own origin, so a type a browser renders
+		// as a document, such as text/html, would let the object's content run as script there.
+		if !pv.allowedContentTypes[mt] {
+			return fmt.Errorf("unsupported response_content_type %s", o.ContentType)
+		}
+	}
+	if o.ContentLanguage != "" && !contentLanguage.MatchString(o.ContentLanguage) {
+		return fmt.Errorf("invalid response_content_language %s. must be a list of language tags", o.ContentLanguage)
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Get", func() {
+	var (
+		aws   *mockPresigner
+		azure *mockPresigner
+		gcp   *mockPresigner
+		hnd   *handler
+	)
+
+	BeforeEach(func() {
+		aws = &mockPresigner{}
+		azure = &mockPresigner{}
+		gcp = &mockPresigner{}
+		hnd = &handler{
+			signers: presign.Registry{
+				v1.ProviderAWS:   aws,
+				v1.ProviderAzure: azure,
+				v1.ProviderGCP:   gcp,
+			},
+			keys: defaultKeyPolicy(),
+		}
+	})
+
+	type getTestCase struct {
+		req                v1.GetObjectRequest
+		mockSetup          func()
+		expectHTTP         int
+		expectTargets      int
+		expectErrSubstr    string
+		expectPresignCalls int
+	}
+
+	DescribeTable("PresignGet",
+		func(tc getTestCase) {
+			if tc.mockSetup != nil {
+				tc.mockSetup()
+			}
+
+			bs, _ := json.Marshal(tc.req)
+			req := httptest.NewRequest(http.MethodPost, "/v1/presign/get", bytes.NewReader(bs))
+			rr := httptest.NewRecorder()
+			hnd.handleGetObject(rr, req)
+
+			Expect(rr.Code).To(Equal(tc.expectHTTP), "body: %s", rr.Body.String())
+
+			var resp v1.GetObjectResponse
+			if rr.Code == http.StatusOK {
+				Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+			}
+			Expect(resp.Targets).To(HaveLen(tc.expectTargets))
+
+			if tc.expectErrSubstr != "" {
+				Expect(rr.Body.String()).To(ContainSubstring(tc.expectErrSubstr))
+			}
+
+			aws.AssertNumberOfCalls(GinkgoT(), "PresignGet", tc.expectPresignCalls)
+			aws.AssertExpectations(GinkgoT())
+			azure.AssertExpectations(GinkgoT())
+			gcp.AssertExpectations(GinkgoT())
+		},
+
+		Entry("success: response overrides reach the presigner", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+				ResponseOverrides: v1.ResponseOverrides{
+					ContentDisposition: `attachment; filename="k1.json"`,
+					CacheControl:       "max-age=60",
+				},
+			},
+			mockSetup: func() {
+				optsMatcher := mock.MatchedBy(func(o presign.GetOptions) bool {
+					return o.TTL == 2*time.Minute &&
+						o.Response.ContentDisposition == `attachment; filename="k1.json"` &&
+						o.Response.CacheControl == "max-age=60"
+				})
+				aws.
+					On("PresignGet", mock.Anything, "bsync-b1", "k1", optsMatcher).
+					Return(&v1.PresignedUrl{
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+						URL:       "https://signed/get",
+					}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("success: version_id selects a historical version", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", VersionID: "3HL4kqtJlcpXroDTDmJ"},
+				},
+			},
+			mockSetup: func() {
+				optsMatcher := mock.MatchedBy(func(o presign.GetOptions) bool {
+					return o.VersionID == "3HL4kqtJlcpXroDTDmJ"
+				})
+				aws.
+					On("PresignGet", mock.Anything, "bsync-b1", "k1", optsMatcher).
+					Return(&v1.PresignedUrl{
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", VersionID: "3HL4kqtJlcpXroDTDmJ"},
+						URL:       "https://signed/get?versionId=3HL4kqtJlcpXroDTDmJ",
+					}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("success: unversioned azure and gcp targets", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets: []v1.TargetRef{
+					{Provider: v1.ProviderAzure, Bucket: "bsync-b1", Key: "k1"},
+					{Provider: v1.ProviderGCP, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			mockSetup: func() {
+				for _, m := range []*mockPresigner{azure, gcp} {
+					m.
+						On("PresignGet", mock.Anything, "bsync-b1", "k1", mock.Anything).
+						Return(&v1.PresignedUrl{URL: "https://signed/get"}, nil).
+						Once()
+				}
+			},
+			expectHTTP:    http.StatusOK,
+			expectTargets: 2,
+		}),
+
+		Entry("success: range is passed to the presigner", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Range:         "bytes=1048576-2097151",
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+			},
+			mockSetup: func() {
+				optsMatcher := mock.MatchedBy(func(o presign.GetOptions) bool {
+					return o.Range == "bytes=1048576-2097151"
+				})
+				aws.
+					On("PresignGet", mock.Anything, "bsync-b1", "k1", optsMatcher).
+					Return(&v1.PresignedUrl{
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+						URL:       "https://signed/get",
+					}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("validation: multi-range reads are rejected", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Range:         "bytes=0-10,20-30",
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "must be a single byte range",
+		}),
+
+		Entry("validation: inverted ranges are rejected", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Range:         "bytes=10-5",
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "first byte must not be after the last",
+		}),
+
+		Entry("validation: gcp generation must be numeric", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets: []v1.TargetRef{
+					{Provider: v1.ProviderGCP, Bucket: "bsync-b1", Key: "k1", VersionID: "abc"},
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "invalid gcp version_id: abc. must be a numeric generation",
+		}),
+
+		Entry("validation: header injection in content disposition", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+				ResponseOverrides: v1.ResponseOverrides{
+					ContentDisposition: "attachment\r\nSet-Cookie: a=b",
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "invalid response_content_disposition. must be printable ASCII",
+		}),
+
+		Entry("validation: unknown disposition type", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+				ResponseOverrides: v1.ResponseOverrides{
+					ContentDisposition: "download",
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "must be inline or attachment",
+		}),
+
+		Entry("validation: malformed content type", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+				ResponseOverrides: v1.ResponseOverrides{
+					ContentType: "text/",
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "invalid response_content_type",
+		}),
+
+		Entry("validation: content type outside the allowlist", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+				ResponseOverrides: v1.ResponseOverrides{
+					ContentType: "text/html; charset=utf-8",
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "unsupported response_content_type text/html; charset=utf-8",
+		}),
+
+		Entry("validation: malformed content language", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+				ResponseOverrides: v1.ResponseOverrides{
+					ContentLanguage: "english language",
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "invalid response_content_language",
+		}),
+
+		Entry("validation: ttl out of range", getTestCase{
+			req: v1.GetObjectRequest{
+				ExpiresMillis: (time.Hour).Milliseconds(),
+				Targets:       []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "expires_ms too large",
+		}),
+	)
+})
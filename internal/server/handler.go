@@ -2,18 +2,40 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/notify"
 	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"github.com/jordanharrington/bsync/internal/verify"
+	"github.com/samber/lo"
 	"net/http"
 	"time"
 )
 
 type handler struct {
-	signers presign.Registry
+	signers    presign.Registry
+	keys       keyPolicy
+	encryption encryptionPolicy
+	verifier   *verify.ContentTypeVerifier
+	// jobs, when set, receives a verification job for every presigned PUT. jobGrace is how long after
+	// the last URL expires the verifier keeps waiting for replicas.
+	jobs     verify.Queue
+	jobGrace time.Duration
+	// uploads, when set, records a session for every presigned PUT that the client finalizes through
+	// /v1/uploads/{id}/complete. replicas checks the targets before a session is committed.
+	uploads  uploads.Store
+	replicas *verify.ReplicationVerifier
+	// events, when set, is told about every completed upload session.
+	events notify.Notifier
+	// proxy, when set, enables /v1/proxy/put, where the gateway streams one client upload to every target.
+	proxy *proxyConfig
+	// aliases, when set, enables /v1/objects/{alias}/{key}, which redirects to the best available replica.
+	aliases *aliasConfig
 }
 
 // handlePutObject handles http.MethodPost to /v1/presign/put
@@ -26,39 +48,226 @@ func (h *handler) handlePutObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := validatePutRequest(in); err != nil {
-		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+	resp, status, err := h.presignPut(ctx, &in)
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	ttl := time.Duration(in.ExpiresMillis) * time.Millisecond
-	urls := make([]v1.PresignedUrl, 0, len(in.ReplicationTargets))
-	for _, s := range in.ReplicationTargets {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// presignPut applies the handler's policies to in, signs a PUT for every target, and opens the upload
+// session and verification job the handler is configured for. On failure it also returns the HTTP status
+// to answer with.
+func (h *handler) presignPut(ctx context.Context, in *v1.PutObjectRequest) (v1.PutObjectResponse, int, error) {
+	var resp v1.PutObjectResponse
+
+	if err := h.applyPolicies(in.ReplicationTargets); err != nil {
+		return resp, http.StatusBadRequest, fmt.Errorf("failed to validate request: %v", err)
+	}
+
+	if err := normalizeRequestMetadata(in); err != nil {
+		return resp, http.StatusBadRequest, fmt.Errorf("failed to validate request: %v", err)
+	}
+
+	if err := validatePutRequest(*in); err != nil {
+		return resp, http.StatusBadRequest, fmt.Errorf("failed to validate request: %v", err)
+	}
+
+	// Nothing but the verification worker fills async targets, so they need a queue to reach it.
+	primaries := primaryTargets(in.ReplicationTargets)
+	if len(primaries) < len(in.ReplicationTargets) && h.jobs == nil {
+		return resp, http.StatusBadRequest, errors.New("failed to validate request: async targets require a verification queue")
+	}
+
+	urls := make([]v1.PresignedUrl, 0, len(primaries))
+	for _, s := range primaries {
 		presigner, ok := h.signers[s.Provider]
 		if !ok {
-			http.Error(w, fmt.Sprintf("provider not configured: %s", s.Provider), http.StatusBadRequest)
-			return
+			return resp, http.StatusBadRequest, fmt.Errorf("provider not configured: %s", s.Provider)
 		}
 
+		to := foldTags(s.Provider, targetOptions(*in, s))
 		opts := presign.NewPutOptions(
-			presign.WithContentType(in.ContentType),
-			presign.WithMetadata(in.Metadata),
-			presign.WithTTL(ttl),
+			presign.WithContentType(to.ContentType),
+			presign.WithMetadata(to.Metadata),
+			presign.WithTTL(time.Duration(to.ExpiresMillis)*time.Millisecond),
 			presign.WithEncryption(s.Encryption),
+			presign.WithStorageClass(to.StorageClass),
+			presign.WithTags(to.Tags),
+			presign.WithIfNoneMatch(to.IfNoneMatch),
+			presign.WithIfMatch(to.IfMatch),
+			presign.WithRetention(to.Retention),
+			presign.WithContentMD5(in.ContentMD5),
 		)
 
 		url, err := presigner.PresignPut(ctx, s.Bucket, s.Key, opts)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("presign failed for %s: %v", s.Provider, err), http.StatusBadGateway)
-			return
+			return resp, http.StatusBadGateway, fmt.Errorf("presign failed for %s: %v", s.Provider, err)
 		}
 
 		urls = append(urls, *url)
 	}
 
+	resp.Targets = urls
+	if len(primaries) < len(in.ReplicationTargets) {
+		resp.Async = uploadTargets(asyncTargets(in.ReplicationTargets))
+	}
+	if h.jobs != nil || h.uploads != nil {
+		id, err := verify.NewJobID()
+		if err != nil {
+			return resp, http.StatusInternalServerError, err
+		}
+
+		if h.uploads != nil {
+			if err := h.createUploadSession(ctx, id, *in, urls); err != nil {
+				return resp, http.StatusServiceUnavailable, fmt.Errorf("failed to create upload session: %v", err)
+			}
+			resp.UploadID = id
+		}
+		if h.jobs != nil {
+			if err := h.enqueueVerification(ctx, id, *in, urls); err != nil {
+				return resp, http.StatusServiceUnavailable, fmt.Errorf("failed to enqueue verification: %v", err)
+			}
+			resp.VerificationID = id
+		}
+	}
+	return resp, http.StatusOK, nil
+}
+
+// enqueueVerification queues a job that checks every target of in once its upload should have finished.
+// The request fails if the job cannot be queued, so that no accepted upload goes unverified.
+func (h *handler) enqueueVerification(ctx context.Context, id string, in v1.PutObjectRequest, urls []v1.PresignedUrl) error {
+	now := time.Now().UTC()
+	job := verify.Job{
+		ID:            id,
+		Targets:       jobTargets(in),
+		ContentType:   in.ContentType,
+		ContentLength: in.ContentLength,
+		ContentMD5:    in.ContentMD5,
+		WriteQuorum:   in.WriteQuorum,
+		Deadline:      latestExpiry(now, urls).Add(h.jobGrace),
+		EnqueuedAt:    now,
+	}
+	return h.jobs.Enqueue(ctx, job)
+}
+
+// uploadTargets strips the per-target options, which only matter at signing time.
+func uploadTargets(targets []v1.TargetRef) []v1.TargetRef {
+	out := make([]v1.TargetRef, len(targets))
+	for i, t := range targets {
+		t.Options = nil
+		out[i] = t
+	}
+	return out
+}
+
+// jobTargets strips the per-target options that only matter at signing time. A target whose effective
+// content type differs from the request's keeps it, so that it is verified against what it was signed
+// with. Async targets also keep their metadata, storage class, tags and retention so that the worker
+// writes the replica the client asked for.
+func jobTargets(in v1.PutObjectRequest) []v1.TargetRef {
+	out := uploadTargets(in.ReplicationTargets)
+	for i, t := range in.ReplicationTargets {
+		o := foldTags(t.Provider, targetOptions(in, t))
+		switch {
+		case t.Role == v1.RoleAsync:
+			out[i].Options = &v1.TargetOptions{
+				ContentType:  o.ContentType,
+				Metadata:     o.Metadata,
+				StorageClass: o.StorageClass,
+				Tags:         o.Tags,
+				Retention:    o.Retention,
+			}
+		case o.ContentType != in.ContentType:
+			out[i].Options = &v1.TargetOptions{ContentType: o.ContentType}
+		}
+	}
+	return out
+}
+
+// primaryTargets returns the targets the client writes itself.
+func primaryTargets(targets []v1.TargetRef) []v1.TargetRef {
+	return lo.Filter(targets, func(t v1.TargetRef, _ int) bool { return t.Role != v1.RoleAsync })
+}
+
+// asyncTargets returns the targets the verification worker fills from a primary.
+func asyncTargets(targets []v1.TargetRef) []v1.TargetRef {
+	return lo.Filter(targets, func(t v1.TargetRef, _ int) bool { return t.Role == v1.RoleAsync })
+}
+
+// latestExpiry returns the latest ExpiresAt of urls, or now if none is later.
+func latestExpiry(now time.Time, urls []v1.PresignedUrl) time.Time {
+	latest := now
+	for _, u := range urls {
+		if u.ExpiresAt.After(latest) {
+			latest = u.ExpiresAt
+		}
+	}
+	return latest
+}
+
+// applyPolicies rewrites targets in place according to the handler's key and encryption policies.
+func (h *handler) applyPolicies(targets []v1.TargetRef) error {
+	if err := h.applyKeyPolicy(targets); err != nil {
+		return err
+	}
+
+	for i, t := range targets {
+		enc, err := h.encryption.apply(t)
+		if err != nil {
+			return err
+		}
+		targets[i].Encryption = enc
+	}
+	return nil
+}
+
+// handleVerifyContentType handles http.MethodPost to /v1/verify/content-type
+func (h *handler) handleVerifyContentType(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var in v1.VerifyContentTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.applyKeyPolicy(in.Targets); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if in.ContentType == "" || !pv.allowedContentTypes[in.ContentType] {
+		http.Error(w, fmt.Sprintf("failed to validate request: unsupported content type %s", in.ContentType), http.StatusBadRequest)
+		return
+	}
+	for _, t := range in.Targets {
+		if err := validateTargetName(t); err != nil {
+			http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results := h.verifier.Verify(ctx, in.ContentType, in.Targets)
+	out := make([]v1.ContentTypeVerification, 0, len(results))
+	for _, res := range results {
+		v := v1.ContentTypeVerification{
+			TargetRef: res.Target,
+			Detected:  res.Detected,
+			Match:     res.Match,
+		}
+		if res.Err != nil {
+			v.Error = res.Err.Error()
+		}
+		out = append(out, v)
+	}
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	_ = json.NewEncoder(w).Encode(v1.PutObjectResponse{
-		Targets: urls,
+	_ = json.NewEncoder(w).Encode(v1.VerifyContentTypeResponse{
+		Results: out,
 	})
 }
 
@@ -66,13 +275,17 @@ var pv = struct {
 	minPresignTTL       time.Duration
 	maxPresignTTL       time.Duration
 	maxMetadataKeys     int
-	maxMetadataSize     int
+	maxTargets          int
 	allowedContentTypes map[string]bool
+	storageClasses      map[v1.StorageClass]bool
+	maxRetention        time.Duration
 }{
 	minPresignTTL:   1 * time.Minute,
 	maxPresignTTL:   10 * time.Minute,
 	maxMetadataKeys: 20,
-	maxMetadataSize: 2048,
+	// An upload session is created in one DynamoDB transaction of at most 100 items: the session and
+	// one index entry per target.
+	maxTargets: 32,
 	allowedContentTypes: map[string]bool{
 		"application/octet-stream": true,
 		"application/json":         true,
@@ -80,19 +293,167 @@ var pv = struct {
 		"image/png":                true,
 		"image/jpeg":               true,
 	},
+	storageClasses: map[v1.StorageClass]bool{
+		v1.StorageHot:     true,
+		v1.StorageCool:    true,
+		v1.StorageCold:    true,
+		v1.StorageArchive: true,
+	},
+	maxRetention: 10 * 365 * 24 * time.Hour,
+}
+
+// targetOptions returns the request-level settings with t's overrides applied.
+func targetOptions(in v1.PutObjectRequest, t v1.TargetRef) v1.TargetOptions {
+	o := v1.TargetOptions{
+		ContentType:   in.ContentType,
+		Metadata:      in.Metadata,
+		ExpiresMillis: in.ExpiresMillis,
+		StorageClass:  in.StorageClass,
+		Tags:          in.Tags,
+		IfNoneMatch:   in.IfNoneMatch,
+		Retention:     in.Retention,
+	}
+	if t.Options == nil {
+		return o
+	}
+
+	if t.Options.ContentType != "" {
+		o.ContentType = t.Options.ContentType
+	}
+	if t.Options.Metadata != nil {
+		o.Metadata = t.Options.Metadata
+	}
+	if t.Options.ExpiresMillis != 0 {
+		o.ExpiresMillis = t.Options.ExpiresMillis
+	}
+	if t.Options.StorageClass != "" {
+		o.StorageClass = t.Options.StorageClass
+	}
+	if t.Options.Tags != nil {
+		o.Tags = t.Options.Tags
+	}
+	if t.Options.Retention != nil {
+		o.Retention = t.Options.Retention
+	}
+	if t.Options.IfNoneMatch != "" || t.Options.IfMatch != "" {
+		o.IfNoneMatch = t.Options.IfNoneMatch
+		o.IfMatch = t.Options.IfMatch
+	}
+	return o
 }
 
 func validatePutRequest(in v1.PutObjectRequest) error {
-	if in.ContentType == "" || !pv.allowedContentTypes[in.ContentType] {
-		return fmt.Errorf("unsupported content type %s", in.ContentType)
+	// Content type, metadata and expiry are checked per target, so a request without targets would skip them.
+	if len(in.ReplicationTargets) == 0 {
+		return errors.New("at least one replication target is required")
+	}
+	if len(in.ReplicationTargets) > pv.maxTargets {
+		return fmt.Errorf("too many replication targets (max %d)", pv.maxTargets)
+	}
+	if in.ContentLength < 0 {
+		return fmt.Errorf("invalid content_length %d. must not be negative", in.ContentLength)
+	}
+	if in.ContentMD5 != "" {
+		if sum, err := base64.StdEncoding.DecodeString(in.ContentMD5); err != nil || len(sum) != 16 {
+			return fmt.Errorf("invalid content_md5 %s. must be a base64-encoded MD5 digest", in.ContentMD5)
+		}
+	}
+
+	for _, s := range in.ReplicationTargets {
+		to := targetOptions(in, s)
+		if err := validateTags(s.Provider, to.Tags); err != nil {
+			return err
+		}
+		if err := validateTargetOptions(s.Provider, foldTags(s.Provider, to)); err != nil {
+			return err
+		}
+		if err := validatePreconditions(s.Provider, to); err != nil {
+			return err
+		}
+		if err := validateRetention(to.Retention); err != nil {
+			return err
+		}
+		// Object Lock writes must carry an integrity header, which the presigned PUT binds to content_md5.
+		if to.Retention != nil && in.ContentMD5 == "" {
+			return errors.New("retention requires content_md5")
+		}
+
+		if err := validateTargetName(s); err != nil {
+			return err
+		}
+		if s.VersionID != "" {
+			return errors.New("version_id is not allowed on put targets")
+		}
+
+		if err := validateEncryption(s.Encryption); err != nil {
+			return err
+		}
 	}
 
-	if len(in.Metadata) > pv.maxMetadataKeys {
+	return validateRoles(in)
+}
+
+// validateRoles checks the role of every target and that the write quorum can be met by the primaries.
+func validateRoles(in v1.PutObjectRequest) error {
+	primaries := 0
+	for _, t := range in.ReplicationTargets {
+		switch t.Role {
+		case "", v1.RolePrimary:
+			primaries++
+		case v1.RoleAsync:
+		default:
+			return fmt.Errorf("unsupported role %s", t.Role)
+		}
+	}
+
+	if primaries == 0 {
+		return errors.New("at least one target must be primary")
+	}
+	if in.WriteQuorum < 0 || in.WriteQuorum > primaries {
+		return fmt.Errorf("invalid write_quorum %d. must be between 0 (all) and the number of primary targets (%d)", in.WriteQuorum, primaries)
+	}
+	return nil
+}
+
+// validateEncryption checks that enc carries exactly the fields its type needs.
+func validateEncryption(enc *v1.EncryptionSpec) error {
+	if enc == nil {
+		return nil
+	}
+
+	switch enc.Type {
+	case v1.EncProviderManaged:
+		if enc.KeyRef != "" {
+			return errors.New("key_ref must be empty for provider_managed")
+		}
+		if enc.CustomerKeyB64 != "" || enc.CustomerKeySHA256B64 != "" {
+			return errors.New("customer-supplied fields not allowed for provider_managed")
+		}
+	case v1.EncCustomerManaged:
+		if enc.KeyRef == "" {
+			return errors.New("key_ref required for customer_managed")
+		}
+		if enc.CustomerKeyB64 != "" || enc.CustomerKeySHA256B64 != "" {
+			return errors.New("customer-supplied fields not allowed for customer_managed")
+		}
+	default:
+		return errors.New("unsupported encryption type")
+	}
+	return nil
+}
+
+// validateTargetOptions checks the effective settings a single target on provider will be signed with.
+func validateTargetOptions(provider v1.Provider, o v1.TargetOptions) error {
+	if o.ContentType == "" || !pv.allowedContentTypes[o.ContentType] {
+		return fmt.Errorf("unsupported content type %s", o.ContentType)
+	}
+
+	if len(o.Metadata) > pv.maxMetadataKeys {
 		return fmt.Errorf("too many metadata entries (max %d)", pv.maxMetadataKeys)
 	}
 
 	total := 0
-	for k, v := range in.Metadata {
+	for k, v := range o.Metadata {
 		if k == "" || len(k) > 128 {
 			return fmt.Errorf("invalid metadata key: %v. must be 1-128 characters", k)
 		}
@@ -101,11 +462,11 @@ func validatePutRequest(in v1.PutObjectRequest) error {
 		}
 		total += len(k) + len(v)
 	}
-	if total > pv.maxMetadataSize {
-		return fmt.Errorf("metadata with %d entries exceeds max size of %d", total, pv.maxMetadataSize)
+	if rule, ok := metadataRules[provider]; ok && total > rule.maxTotal {
+		return fmt.Errorf("metadata size %d exceeds %s limit of %d bytes", total, provider, rule.maxTotal)
 	}
 
-	d := time.Duration(in.ExpiresMillis) * time.Millisecond
+	d := time.Duration(o.ExpiresMillis) * time.Millisecond
 	if d < pv.minPresignTTL {
 		return fmt.Errorf("expires_ms too small (min %d ms)", pv.minPresignTTL.Milliseconds())
 	}
@@ -113,50 +474,212 @@ func validatePutRequest(in v1.PutObjectRequest) error {
 		return fmt.Errorf("expires_ms too large (max %d ms)", pv.maxPresignTTL.Milliseconds())
 	}
 
-	for _, s := range in.ReplicationTargets {
-		if s.Bucket == "" || len(s.Bucket) > 63 {
-			return fmt.Errorf("invalid bucket name: %s. must be 1-63 characters", s.Bucket)
-		}
-
-		if s.Key == "" || len(s.Key) > 1024 {
-			return fmt.Errorf("invalid key: %s. must be 1-1024 characters", s.Key)
-		}
-
-		if s.Encryption != nil {
-			enc := s.Encryption
-			switch enc.Type {
-			case v1.EncProviderManaged:
-				if enc.KeyRef != "" {
-					return errors.New("key_ref must be empty for provider_managed")
-				}
-				if enc.CustomerKeyB64 != "" || enc.CustomerKeySHA256B64 != "" {
-					return errors.New("customer-supplied fields not allowed for provider_managed")
-				}
-			case v1.EncCustomerManaged:
-				if enc.KeyRef == "" {
-					return errors.New("key_ref required for customer_managed")
-				}
-				if enc.CustomerKeyB64 != "" || enc.CustomerKeySHA256B64 != "" {
-					return errors.New("customer-supplied fields not allowed for customer_managed")
-				}
-			default:
-				return errors.New("unsupported encryption type")
+	if o.StorageClass != "" && !pv.storageClasses[o.StorageClass] {
+		return fmt.Errorf("unsupported storage class %s", o.StorageClass)
+	}
+
+	return nil
+}
+
+// validatePreconditions checks the conditional-write options. S3 and Azure bind If-None-Match/If-Match
+// directly; GCS expresses create-only as x-goog-if-generation-match: 0 and has no ETag precondition.
+func validatePreconditions(provider v1.Provider, o v1.TargetOptions) error {
+	if o.IfNoneMatch != "" && o.IfNoneMatch != "*" {
+		return fmt.Errorf("unsupported if_none_match %s. only \"*\" is supported", o.IfNoneMatch)
+	}
+	if o.IfNoneMatch != "" && o.IfMatch != "" {
+		return errors.New("if_none_match and if_match are mutually exclusive")
+	}
+	if o.IfMatch != "" {
+		if provider == v1.ProviderGCP {
+			return errors.New("if_match is not supported for gcp")
+		}
+		for _, r := range o.IfMatch {
+			if r < 0x21 || r > 0x7e {
+				return fmt.Errorf("invalid if_match %q. must be an ETag", o.IfMatch)
 			}
 		}
 	}
+	return nil
+}
+
+// validateRetention checks a WORM retention spec against the policy maximum.
+func validateRetention(r *v1.RetentionSpec) error {
+	if r == nil {
+		return nil
+	}
 
+	switch r.Mode {
+	case "":
+		if r.RetainUntil != nil {
+			return errors.New("retention mode required with retain_until")
+		}
+		if !r.LegalHold {
+			return errors.New("retention requires a mode or legal_hold")
+		}
+	case v1.RetentionGovernance, v1.RetentionCompliance:
+		if r.RetainUntil == nil {
+			return fmt.Errorf("retain_until required for %s retention", r.Mode)
+		}
+		d := time.Until(*r.RetainUntil)
+		if d <= 0 {
+			return errors.New("retain_until must be in the future")
+		}
+		if d > pv.maxRetention {
+			return fmt.Errorf("retain_until too far in the future (max %d days)", int(pv.maxRetention.Hours()/24))
+		}
+	default:
+		return fmt.Errorf("unsupported retention mode %s", r.Mode)
+	}
 	return nil
 }
 
-func NewRouter(ctx context.Context, provider v1.Provider) (*mux.Router, error) {
+// RouterOption mutates the handler built by NewRouter.
+type RouterOption func(*handler)
+
+// WithReservedKeyPrefixes replaces the key prefixes clients may not write to.
+func WithReservedKeyPrefixes(prefixes ...string) RouterOption {
+	return func(h *handler) { h.keys.reservedPrefixes = prefixes }
+}
+
+// WithKeyNormalization toggles Unicode NFC normalization of object keys.
+func WithKeyNormalization(enabled bool) RouterOption {
+	return func(h *handler) { h.keys.normalizeNFC = enabled }
+}
+
+// WithRequiredEncryption rejects writes to the given buckets of provider unless they are customer_managed
+// with a key_ref allowed by WithAllowedKeyRefs.
+func WithRequiredEncryption(provider v1.Provider, buckets ...string) RouterOption {
+	return func(h *handler) { h.encryption.requireFor(provider, buckets...) }
+}
+
+// WithAllowedKeyRefs restricts customer_managed key_ref values for provider to refs.
+func WithAllowedKeyRefs(provider v1.Provider, refs ...string) RouterOption {
+	return func(h *handler) { h.encryption.allowKeyRefs(provider, refs...) }
+}
+
+// WithDefaultEncryption injects spec into provider targets that omit an encryption spec.
+func WithDefaultEncryption(provider v1.Provider, spec v1.EncryptionSpec) RouterOption {
+	return func(h *handler) { h.encryption.defaultFor(provider, spec) }
+}
+
+// WithContentTypeVerification enables /v1/verify/content-type, which sniffs uploaded replicas
+// and flags those whose bytes do not match the declared content type.
+func WithContentTypeVerification(opts ...verify.Option) RouterOption {
+	return func(h *handler) { h.verifier = verify.NewContentTypeVerifier(h.signers, opts...) }
+}
+
+// WithVerificationQueue enqueues a verification job onto q for every presigned PUT. The verifier waits
+// up to grace past the expiry of the last URL for replicas to appear.
+func WithVerificationQueue(q verify.Queue, grace time.Duration) RouterOption {
+	return func(h *handler) {
+		h.jobs = q
+		h.jobGrace = grace
+	}
+}
+
+// WithUploadSessions records an upload session in store for every presigned PUT and enables
+// /v1/uploads/{id}/complete and /v1/objects/status. opts configure the HEAD checks run before a session is committed.
+func WithUploadSessions(store uploads.Store, opts ...verify.ReplicationOption) RouterOption {
+	return func(h *handler) {
+		h.uploads = store
+		h.replicas = verify.NewReplicationVerifier(h.signers, opts...)
+	}
+}
+
+// WithNotifier sends an upload.committed or upload.failed event to n whenever an upload session is completed.
+func WithNotifier(n notify.Notifier) RouterOption {
+	return func(h *handler) { h.events = n }
+}
+
+// WithProxyUploads enables /v1/proxy/put, where the client sends the body once and the gateway streams it
+// to every target. It is meant for the standalone server; API Gateway limits request bodies to a few MB.
+func WithProxyUploads(opts ...ProxyOption) RouterOption {
+	return func(h *handler) {
+		p := &proxyConfig{
+			client:    http.DefaultClient,
+			chunkSize: 1 << 20,
+			maxSize:   5 << 30,
+		}
+		for _, opt := range opts {
+			opt(p)
+		}
+		h.proxy = p
+	}
+}
+
+// WithAliases enables GET /v1/objects/{alias}/{key} for aliases. opts configure the HEAD checks that pick
+// a replica before redirecting to it.
+func WithAliases(aliases []Alias, opts ...verify.ReplicationOption) RouterOption {
+	return func(h *handler) {
+		byName := make(map[string]Alias, len(aliases))
+		for _, a := range aliases {
+			byName[a.Name] = a
+		}
+		h.aliases = &aliasConfig{
+			aliases:   byName,
+			replicas:  verify.NewReplicationVerifier(h.signers, opts...),
+			ttl:       5 * time.Minute,
+			cooldown:  30 * time.Second,
+			unhealthy: map[string]time.Time{},
+		}
+	}
+}
+
+func NewRouter(ctx context.Context, provider v1.Provider, opts ...RouterOption) (*mux.Router, error) {
 	presignRegistry, err := presign.NewRegistry(ctx, provider)
 	if err != nil {
 		return nil, err
 	}
 
-	h := handler{signers: presignRegistry}
-	m := mux.NewRouter().StrictSlash(true).PathPrefix("/v1/presign").Subrouter()
-	m.HandleFunc("/put", h.handlePutObject).Methods(http.MethodPost)
+	h := handler{
+		signers: presignRegistry,
+		keys:    defaultKeyPolicy(),
+	}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	return h.routes(), nil
+}
+
+// routes registers the endpoints h is configured for.
+func (h *handler) routes() *mux.Router {
+	// Object keys are part of some paths, so they must reach the handlers exactly as sent: cleaning would
+	// turn a//b into a/b and redirect the caller to a different object.
+	m := mux.NewRouter().StrictSlash(true).SkipClean(true)
+	p := m.PathPrefix("/v1/presign").Subrouter()
+	p.HandleFunc("/put", h.handlePutObject).Methods(http.MethodPost)
+	p.HandleFunc("/get", h.handleGetObject).Methods(http.MethodPost)
+	p.HandleFunc("/head", h.handleHeadObject).Methods(http.MethodPost)
+	p.HandleFunc("/delete", h.handleDeleteObject).Methods(http.MethodPost)
+	p.HandleFunc("/copy", h.handleCopyObject).Methods(http.MethodPost)
+
+	if h.verifier != nil {
+		v := m.PathPrefix("/v1/verify").Subrouter()
+		v.HandleFunc("/content-type", h.handleVerifyContentType).Methods(http.MethodPost)
+	}
+
+	if h.proxy != nil {
+		x := m.PathPrefix("/v1/proxy").Subrouter()
+		x.HandleFunc("/put", h.handleProxyUpload).Methods(http.MethodPost)
+	}
+
+	if h.uploads != nil {
+		u := m.PathPrefix("/v1/uploads").Subrouter()
+		u.HandleFunc("/{id}/complete", h.handleCompleteUpload).Methods(http.MethodPost)
+	}
+
+	if h.uploads != nil || h.aliases != nil {
+		o := m.PathPrefix("/v1/objects").Subrouter()
+		if h.uploads != nil {
+			o.HandleFunc("/status", h.handleObjectStatus).Methods(http.MethodGet)
+		}
+		if h.aliases != nil {
+			// A key may end in a slash, which StrictSlash would strip with a redirect.
+			o.StrictSlash(false)
+			o.HandleFunc("/{alias}/{key:.+}", h.handleObjectRedirect).Methods(http.MethodGet)
+		}
+	}
 
-	return m, nil
+	return m
 }
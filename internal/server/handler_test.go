@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	v1 "github.com/jordanharrington/bsync/api/v1"
 	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/verify"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"time"
 )
 
@@ -31,6 +35,30 @@ func (m *mockPresigner) PresignPut(ctx context.Context, bucket, key string, opts
 	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
 }
 
+func (m *mockPresigner) PresignGet(ctx context.Context, bucket, key string, opts presign.GetOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
+func (m *mockPresigner) PresignHead(ctx context.Context, bucket, key string, opts presign.HeadOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
+func (m *mockPresigner) PresignDelete(ctx context.Context, bucket, key string, opts presign.DeleteOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
+func (m *mockPresigner) PresignCopy(ctx context.Context, bucket, key string, opts presign.CopyOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
 var _ = Describe("Handler", func() {
 	var (
 		aws *mockPresigner
@@ -43,6 +71,7 @@ var _ = Describe("Handler", func() {
 			signers: presign.Registry{
 				v1.ProviderAWS: aws,
 			},
+			keys: defaultKeyPolicy(),
 		}
 	})
 
@@ -100,7 +129,7 @@ var _ = Describe("Handler", func() {
 				ReplicationTargets: []v1.TargetRef{
 					{
 						Provider: v1.ProviderAWS,
-						Bucket:   "b1",
+						Bucket:   "bsync-b1",
 						Key:      "k1",
 						Encryption: &v1.EncryptionSpec{
 							Type: v1.EncProviderManaged,
@@ -113,9 +142,9 @@ var _ = Describe("Handler", func() {
 					return o.Encryption != nil && o.Encryption.Type == v1.EncProviderManaged && o.Encryption.KeyRef == ""
 				})
 				aws.
-					On("PresignPut", mock.Anything, "b1", "k1", optsMatcher).
+					On("PresignPut", mock.Anything, "bsync-b1", "k1", optsMatcher).
 					Return(&v1.PresignedUrl{
-						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "b1", Key: "k1"},
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
 						URL:       "https://signed/provider-managed",
 						Headers:   map[string]string{"ok": "1"},
 					}, nil).
@@ -133,7 +162,7 @@ var _ = Describe("Handler", func() {
 				ReplicationTargets: []v1.TargetRef{
 					{
 						Provider: v1.ProviderAWS,
-						Bucket:   "b1",
+						Bucket:   "bsync-b1",
 						Key:      "k1",
 						Encryption: &v1.EncryptionSpec{
 							Type:   v1.EncCustomerManaged,
@@ -149,9 +178,9 @@ var _ = Describe("Handler", func() {
 						o.Encryption.KeyRef == "arn:aws:kms:us-east-1:111122223333:key/abcd-ef"
 				})
 				aws.
-					On("PresignPut", mock.Anything, "b1", "k1", optsMatcher).
+					On("PresignPut", mock.Anything, "bsync-b1", "k1", optsMatcher).
 					Return(&v1.PresignedUrl{
-						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "b1", Key: "k1"},
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
 						URL:       "https://signed/kms",
 						Headers:   map[string]string{"ok": "1"},
 					}, nil).
@@ -162,13 +191,331 @@ var _ = Describe("Handler", func() {
 			expectPresignCalls: 1,
 		}),
 
+		Entry("success: per-target overrides replace request-level settings", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				Metadata:      map[string]string{"owner": "team-a"},
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+					{
+						Provider: v1.ProviderAWS, Bucket: "bsync-archive", Key: "k1",
+						Options: &v1.TargetOptions{
+							ContentType:   "application/octet-stream",
+							Metadata:      map[string]string{"Tier": "archive"},
+							ExpiresMillis: (5 * time.Minute).Milliseconds(),
+							StorageClass:  v1.StorageArchive,
+						},
+					},
+				},
+			},
+			mockSetup: func() {
+				primary := mock.MatchedBy(func(o presign.PutOptions) bool {
+					return o.ContentType == "application/json" &&
+						o.Metadata["owner"] == "team-a" &&
+						o.TTL == 2*time.Minute &&
+						o.StorageClass == ""
+				})
+				archive := mock.MatchedBy(func(o presign.PutOptions) bool {
+					return o.ContentType == "application/octet-stream" &&
+						len(o.Metadata) == 1 && o.Metadata["tier"] == "archive" &&
+						o.TTL == 5*time.Minute &&
+						o.StorageClass == v1.StorageArchive
+				})
+				for bucket, matcher := range map[string]interface{}{"bsync-b1": primary, "bsync-archive": archive} {
+					aws.
+						On("PresignPut", mock.Anything, bucket, "k1", matcher).
+						Return(&v1.PresignedUrl{
+							TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: bucket, Key: "k1"},
+							URL:       "https://signed/" + bucket,
+							Headers:   map[string]string{"ok": "1"},
+						}, nil).
+						Once()
+				}
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      2,
+			expectPresignCalls: 2,
+		}),
+
+		Entry("validation: unsupported storage class override", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{
+						Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1",
+						Options: &v1.TargetOptions{StorageClass: "frozen"},
+					},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "unsupported storage class frozen",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("success: if_none_match is passed to every target", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				IfNoneMatch:   "*",
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			mockSetup: func() {
+				optsMatcher := mock.MatchedBy(func(o presign.PutOptions) bool {
+					return o.IfNoneMatch == "*" && o.IfMatch == ""
+				})
+				aws.
+					On("PresignPut", mock.Anything, "bsync-b1", "k1", optsMatcher).
+					Return(&v1.PresignedUrl{
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+						URL:       "https://signed/create-only",
+						Headers:   map[string]string{"If-None-Match": "*"},
+					}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("validation: if_none_match only accepts *", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				IfNoneMatch:   `"abc"`,
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "only \"*\" is supported",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: if_match is not supported for gcp", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{
+						Provider: v1.ProviderGCP, Bucket: "bsync-b1", Key: "k1",
+						Options: &v1.TargetOptions{IfMatch: `"abc"`},
+					},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "if_match is not supported for gcp",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("success: retention is passed to the presigner", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ContentMD5:    "1B2M2Y8AsgTpgAmY7PhCfg==",
+				Retention: &v1.RetentionSpec{
+					Mode:        v1.RetentionGovernance,
+					RetainUntil: lo.ToPtr(time.Now().Add(30 * 24 * time.Hour)),
+				},
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			mockSetup: func() {
+				optsMatcher := mock.MatchedBy(func(o presign.PutOptions) bool {
+					return o.Retention != nil && o.Retention.Mode == v1.RetentionGovernance &&
+						o.ContentMD5 == "1B2M2Y8AsgTpgAmY7PhCfg=="
+				})
+				aws.
+					On("PresignPut", mock.Anything, "bsync-b1", "k1", optsMatcher).
+					Return(&v1.PresignedUrl{
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+						URL:       "https://signed/worm",
+						Headers:   map[string]string{"ok": "1"},
+					}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("validation: retention without content_md5", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Retention:     &v1.RetentionSpec{LegalHold: true},
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "retention requires content_md5",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: retention beyond the policy maximum", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Retention: &v1.RetentionSpec{
+					Mode:        v1.RetentionCompliance,
+					RetainUntil: lo.ToPtr(time.Now().Add(20 * 365 * 24 * time.Hour)),
+				},
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "retain_until too far in the future (max 3650 days)",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: retention mode without retain_until", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Retention:     &v1.RetentionSpec{Mode: v1.RetentionCompliance},
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "retain_until required for compliance retention",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: retain_until in the past", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Retention: &v1.RetentionSpec{
+					Mode:        v1.RetentionGovernance,
+					RetainUntil: lo.ToPtr(time.Now().Add(-time.Hour)),
+				},
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "retain_until must be in the future",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: no replication targets", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:        "application/x-unknown",
+				ExpiresMillis:      1,
+				ReplicationTargets: []v1.TargetRef{},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "at least one replication target is required",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: too many replication targets", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:        "application/json",
+				ExpiresMillis:      (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: make([]v1.TargetRef, 33),
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "too many replication targets (max 32)",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: version_id is not allowed on put targets", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", VersionID: "v1"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "version_id is not allowed on put targets",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: unknown target role", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", Role: "eventual"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "unsupported role eventual",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: every target async", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", Role: v1.RoleAsync},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "at least one target must be primary",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: write_quorum above the number of primaries", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				WriteQuorum:   2,
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1", Role: v1.RoleAsync},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "invalid write_quorum 2. must be between 0 (all) and the number of primary targets (1)",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: async targets without a verification queue", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", Role: v1.RolePrimary},
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1", Role: v1.RoleAsync},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "async targets require a verification queue",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
 		Entry("validation: provider_managed must not set key_ref", putTestCase{
 			req: v1.PutObjectRequest{
 				ContentType:   "application/json",
 				ExpiresMillis: (2 * time.Minute).Milliseconds(),
 				ReplicationTargets: []v1.TargetRef{
 					{
-						Provider: v1.ProviderAWS, Bucket: "b1", Key: "k1",
+						Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1",
 						Encryption: &v1.EncryptionSpec{
 							Type:   v1.EncProviderManaged,
 							KeyRef: "should-not-be-here",
@@ -188,7 +535,7 @@ var _ = Describe("Handler", func() {
 				ExpiresMillis: (2 * time.Minute).Milliseconds(),
 				ReplicationTargets: []v1.TargetRef{
 					{
-						Provider: v1.ProviderAWS, Bucket: "b1", Key: "k1",
+						Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1",
 						Encryption: &v1.EncryptionSpec{
 							Type:                 v1.EncProviderManaged,
 							CustomerKeyB64:       "abc",
@@ -209,7 +556,7 @@ var _ = Describe("Handler", func() {
 				ExpiresMillis: (2 * time.Minute).Milliseconds(),
 				ReplicationTargets: []v1.TargetRef{
 					{
-						Provider: v1.ProviderAWS, Bucket: "b1", Key: "k1",
+						Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1",
 						Encryption: &v1.EncryptionSpec{Type: v1.EncCustomerManaged},
 					},
 				},
@@ -226,7 +573,7 @@ var _ = Describe("Handler", func() {
 				ExpiresMillis: (2 * time.Minute).Milliseconds(),
 				ReplicationTargets: []v1.TargetRef{
 					{
-						Provider: v1.ProviderAWS, Bucket: "b1", Key: "k1",
+						Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1",
 						Encryption: &v1.EncryptionSpec{
 							Type:                 v1.EncCustomerManaged,
 							KeyRef:               "arn:aws:kms:us-east-1:111122223333:key/abcd-ef",
@@ -242,13 +589,87 @@ var _ = Describe("Handler", func() {
 			expectTargets:      0,
 		}),
 
+		Entry("validation: invalid bucket name is rejected before signing", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "Bad_Bucket", Key: "k1"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "invalid aws bucket name: Bad_Bucket",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("success: key is normalized to NFC before signing", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "cafe\u0301.json"},
+				},
+			},
+			mockSetup: func() {
+				aws.
+					On("PresignPut", mock.Anything, "bsync-b1", "caf\u00e9.json", mock.Anything).
+					Return(&v1.PresignedUrl{
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "caf\u00e9.json"},
+						URL:       "https://signed/nfc",
+						Headers:   map[string]string{"ok": "1"},
+					}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("validation: reserved key prefix is rejected", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "_internal/state"},
+				},
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "prefix _internal/ is reserved",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
+		Entry("validation: customer_managed key_ref outside the allowlist", putTestCase{
+			req: v1.PutObjectRequest{
+				ContentType:   "application/json",
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				ReplicationTargets: []v1.TargetRef{
+					{
+						Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1",
+						Encryption: &v1.EncryptionSpec{
+							Type:   v1.EncCustomerManaged,
+							KeyRef: "arn:aws:kms:us-east-1:111122223333:key/not-allowed",
+						},
+					},
+				},
+			},
+			mockSetup: func() {
+				WithAllowedKeyRefs(v1.ProviderAWS, "arn:aws:kms:us-east-1:111122223333:key/abcd-ef")(hnd)
+			},
+			expectHTTP:         http.StatusBadRequest,
+			expectErrSubstr:    "is not allowed for aws",
+			expectPresignCalls: 0,
+			expectTargets:      0,
+		}),
+
 		Entry("validation: unsupported encryption type", putTestCase{
 			req: v1.PutObjectRequest{
 				ContentType:   "application/json",
 				ExpiresMillis: (2 * time.Minute).Milliseconds(),
 				ReplicationTargets: []v1.TargetRef{
 					{
-						Provider: v1.ProviderAWS, Bucket: "b1", Key: "k1",
+						Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1",
 						Encryption: &v1.EncryptionSpec{Type: "totally_unknown"},
 					},
 				},
@@ -259,4 +680,264 @@ var _ = Describe("Handler", func() {
 			expectTargets:      0,
 		}),
 	)
+
+	It("applies each provider's own metadata size limit", func() {
+		gcp := &mockPresigner{}
+		hnd.signers[v1.ProviderGCP] = gcp
+		gcp.
+			On("PresignPut", mock.Anything, "bsync-b1", "k1", mock.Anything).
+			Return(&v1.PresignedUrl{URL: "https://signed/gcp"}, nil).
+			Once()
+
+		md := map[string]string{"a": strings.Repeat("x", 1000), "b": strings.Repeat("x", 1000), "c": strings.Repeat("x", 1000)}
+		put := func(provider v1.Provider) *httptest.ResponseRecorder {
+			bs, _ := json.Marshal(v1.PutObjectRequest{
+				ContentType:        "application/json",
+				ExpiresMillis:      (2 * time.Minute).Milliseconds(),
+				Metadata:           md,
+				ReplicationTargets: []v1.TargetRef{{Provider: provider, Bucket: "bsync-b1", Key: "k1"}},
+			})
+			rr := httptest.NewRecorder()
+			hnd.handlePutObject(rr, httptest.NewRequest(http.MethodPost, "/v1/presign/put", bytes.NewReader(bs)))
+			return rr
+		}
+
+		Expect(put(v1.ProviderGCP).Code).To(Equal(http.StatusOK))
+		rr := put(v1.ProviderAWS)
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		Expect(rr.Body.String()).To(ContainSubstring("exceeds aws limit of 2048 bytes"))
+		gcp.AssertExpectations(GinkgoT())
+	})
+})
+
+type failingQueue struct {
+	verify.Queue
+}
+
+func (failingQueue) Enqueue(context.Context, verify.Job) error {
+	return errors.New("queue unavailable")
+}
+
+var _ = Describe("VerificationQueue", func() {
+	var (
+		aws  *mockPresigner
+		hnd  *handler
+		jobs *verify.MemoryQueue
+	)
+
+	BeforeEach(func() {
+		aws = &mockPresigner{}
+		jobs = verify.NewMemoryQueue()
+		hnd = &handler{
+			signers: presign.Registry{
+				v1.ProviderAWS: aws,
+			},
+			keys: defaultKeyPolicy(),
+		}
+		WithVerificationQueue(jobs, 10*time.Minute)(hnd)
+	})
+
+	put := func(in v1.PutObjectRequest) *httptest.ResponseRecorder {
+		bs, _ := json.Marshal(in)
+		req := httptest.NewRequest(http.MethodPost, "/v1/presign/put", bytes.NewReader(bs))
+		rr := httptest.NewRecorder()
+		hnd.handlePutObject(rr, req)
+		return rr
+	}
+
+	request := v1.PutObjectRequest{
+		ContentType:   "image/png",
+		ExpiresMillis: (2 * time.Minute).Milliseconds(),
+		ContentLength: 1024,
+		ContentMD5:    "1B2M2Y8AsgTpgAmY7PhCfg==",
+		ReplicationTargets: []v1.TargetRef{
+			{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", Options: &v1.TargetOptions{StorageClass: v1.StorageCool}},
+		},
+	}
+
+	It("enqueues a job for every presigned PUT", func() {
+		expiresAt := time.Now().Add(2 * time.Minute).UTC().Truncate(time.Second)
+		aws.
+			On("PresignPut", mock.Anything, "bsync-b1", "k1", mock.Anything).
+			Return(&v1.PresignedUrl{
+				TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				URL:       "https://signed/put",
+				ExpiresAt: expiresAt,
+			}, nil).
+			Once()
+
+		rr := put(request)
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+
+		var resp v1.PutObjectResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.VerificationID).NotTo(BeEmpty())
+
+		Expect(jobs.Len()).To(Equal(1))
+		d, err := jobs.Receive(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Job.ID).To(Equal(resp.VerificationID))
+		Expect(d.Job.ContentType).To(Equal("image/png"))
+		Expect(d.Job.ContentLength).To(Equal(int64(1024)))
+		Expect(d.Job.ContentMD5).To(Equal("1B2M2Y8AsgTpgAmY7PhCfg=="))
+		Expect(d.Job.Deadline).To(Equal(expiresAt.Add(10 * time.Minute)))
+		Expect(d.Job.Targets).To(ConsistOf(v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}))
+	})
+
+	It("carries a per-target content type override on the job", func() {
+		in := request
+		in.ReplicationTargets = []v1.TargetRef{
+			{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+			{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1", Options: &v1.TargetOptions{ContentType: "image/jpeg"}},
+		}
+		aws.
+			On("PresignPut", mock.Anything, mock.Anything, "k1", mock.Anything).
+			Return(&v1.PresignedUrl{URL: "https://signed/put"}, nil)
+
+		rr := put(in)
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+
+		d, err := jobs.Receive(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Job.Targets).To(HaveLen(2))
+		Expect(d.Job.TargetContentType(d.Job.Targets[0])).To(Equal("image/png"))
+		Expect(d.Job.TargetContentType(d.Job.Targets[1])).To(Equal("image/jpeg"))
+	})
+
+	It("fails the request when the job cannot be queued", func() {
+		WithVerificationQueue(failingQueue{}, time.Minute)(hnd)
+		aws.
+			On("PresignPut", mock.Anything, "bsync-b1", "k1", mock.Anything).
+			Return(&v1.PresignedUrl{URL: "https://signed/put"}, nil).
+			Once()
+
+		rr := put(request)
+		Expect(rr.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(rr.Body.String()).To(ContainSubstring("queue unavailable"))
+	})
+
+	It("only signs primary targets and queues the async ones for the verifier", func() {
+		in := request
+		in.ReplicationTargets = []v1.TargetRef{
+			{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+			{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1", Role: v1.RoleAsync, Options: &v1.TargetOptions{StorageClass: v1.StorageCool}},
+		}
+		aws.
+			On("PresignPut", mock.Anything, "bsync-b1", "k1", mock.Anything).
+			Return(&v1.PresignedUrl{URL: "https://signed/put"}, nil).
+			Once()
+
+		rr := put(in)
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+
+		var resp v1.PutObjectResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Targets).To(HaveLen(1))
+		async := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1", Role: v1.RoleAsync}
+		Expect(resp.Async).To(ConsistOf(async))
+		aws.AssertNotCalled(GinkgoT(), "PresignPut", mock.Anything, "bsync-b2", mock.Anything, mock.Anything)
+
+		d, err := jobs.Receive(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		async.Options = &v1.TargetOptions{ContentType: "image/png", StorageClass: v1.StorageCool}
+		Expect(d.Job.Targets).To(ContainElement(async))
+	})
+
+	It("rejects a content_md5 that is not an MD5 digest", func() {
+		in := request
+		in.ContentMD5 = "not-a-digest"
+
+		rr := put(in)
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		Expect(rr.Body.String()).To(ContainSubstring("invalid content_md5"))
+		Expect(jobs.Len()).To(BeZero())
+		aws.AssertNumberOfCalls(GinkgoT(), "PresignPut", 0)
+	})
+})
+
+var _ = Describe("VerifyContentType", func() {
+	var (
+		aws *mockPresigner
+		hnd *handler
+		srv *httptest.Server
+	)
+
+	BeforeEach(func() {
+		aws = &mockPresigner{}
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("\x7fELF\x02\x01\x01\x00"))
+		}))
+		DeferCleanup(srv.Close)
+
+		hnd = &handler{
+			signers: presign.Registry{
+				v1.ProviderAWS: aws,
+			},
+			keys: defaultKeyPolicy(),
+		}
+		WithContentTypeVerification(verify.WithHTTPClient(srv.Client()))(hnd)
+	})
+
+	post := func(in v1.VerifyContentTypeRequest) *httptest.ResponseRecorder {
+		bs, _ := json.Marshal(in)
+		req := httptest.NewRequest(http.MethodPost, "/v1/verify/content-type", bytes.NewReader(bs))
+		rr := httptest.NewRecorder()
+		hnd.handleVerifyContentType(rr, req)
+		return rr
+	}
+
+	It("flags a replica whose bytes do not match the declared type", func() {
+		aws.
+			On("PresignGet", mock.Anything, "bsync-b1", "k1", mock.Anything).
+			Return(&v1.PresignedUrl{
+				TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"},
+				URL:       srv.URL + "/k1",
+			}, nil).
+			Once()
+
+		rr := post(v1.VerifyContentTypeRequest{
+			ContentType: "image/png",
+			Targets:     []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+		})
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+
+		var resp v1.VerifyContentTypeResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Results).To(HaveLen(1))
+		Expect(resp.Results[0].Match).To(BeFalse())
+		Expect(resp.Results[0].Detected).To(Equal("application/octet-stream"))
+		aws.AssertExpectations(GinkgoT())
+	})
+
+	It("applies the key policy before sniffing", func() {
+		aws.
+			On("PresignGet", mock.Anything, "bsync-b1", "caf\u00e9.png", mock.Anything).
+			Return(&v1.PresignedUrl{URL: srv.URL + "/cafe"}, nil).
+			Once()
+
+		rr := post(v1.VerifyContentTypeRequest{
+			ContentType: "image/png",
+			Targets:     []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "cafe\u0301.png"}},
+		})
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+		aws.AssertExpectations(GinkgoT())
+
+		rr = post(v1.VerifyContentTypeRequest{
+			ContentType: "image/png",
+			Targets:     []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "_internal/k1"}},
+		})
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		Expect(rr.Body.String()).To(ContainSubstring("prefix _internal/ is reserved"))
+		aws.AssertNumberOfCalls(GinkgoT(), "PresignGet", 1)
+	})
+
+	It("rejects content types outside the allowlist", func() {
+		rr := post(v1.VerifyContentTypeRequest{
+			ContentType: "application/x-msdownload",
+			Targets:     []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}},
+		})
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		Expect(rr.Body.String()).To(ContainSubstring("unsupported content type"))
+		aws.AssertNumberOfCalls(GinkgoT(), "PresignGet", 0)
+	})
 })
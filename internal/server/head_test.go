@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Head", func() {
+	var (
+		aws *mockPresigner
+		hnd *handler
+	)
+
+	BeforeEach(func() {
+		aws = &mockPresigner{}
+		hnd = &handler{
+			signers: presign.Registry{
+				v1.ProviderAWS: aws,
+			},
+			keys: defaultKeyPolicy(),
+		}
+	})
+
+	type headTestCase struct {
+		req                v1.HeadObjectRequest
+		mockSetup          func()
+		expectHTTP         int
+		expectTargets      int
+		expectErrSubstr    string
+		expectPresignCalls int
+	}
+
+	DescribeTable("PresignHead",
+		func(tc headTestCase) {
+			if tc.mockSetup != nil {
+				tc.mockSetup()
+			}
+
+			bs, _ := json.Marshal(tc.req)
+			req := httptest.NewRequest(http.MethodPost, "/v1/presign/head", bytes.NewReader(bs))
+			rr := httptest.NewRecorder()
+			hnd.handleHeadObject(rr, req)
+
+			Expect(rr.Code).To(Equal(tc.expectHTTP), "body: %s", rr.Body.String())
+
+			var resp v1.HeadObjectResponse
+			if rr.Code == http.StatusOK {
+				Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+			}
+			Expect(resp.Targets).To(HaveLen(tc.expectTargets))
+
+			if tc.expectErrSubstr != "" {
+				Expect(rr.Body.String()).To(ContainSubstring(tc.expectErrSubstr))
+			}
+
+			aws.AssertNumberOfCalls(GinkgoT(), "PresignHead", tc.expectPresignCalls)
+			aws.AssertExpectations(GinkgoT())
+		},
+
+		Entry("success: version_id heads a specific version", headTestCase{
+			req: v1.HeadObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", VersionID: "v2"},
+				},
+			},
+			mockSetup: func() {
+				optsMatcher := mock.MatchedBy(func(o presign.HeadOptions) bool {
+					return o.VersionID == "v2" && o.TTL == 2*time.Minute
+				})
+				aws.
+					On("PresignHead", mock.Anything, "bsync-b1", "k1", optsMatcher).
+					Return(&v1.PresignedUrl{
+						TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1", VersionID: "v2"},
+						URL:       "https://signed/head?versionId=v2",
+					}, nil).
+					Once()
+			},
+			expectHTTP:         http.StatusOK,
+			expectTargets:      1,
+			expectPresignCalls: 1,
+		}),
+
+		Entry("validation: azure version_id must be a timestamp", headTestCase{
+			req: v1.HeadObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets: []v1.TargetRef{
+					{Provider: v1.ProviderAzure, Bucket: "bsync-b1", Key: "k1", VersionID: "v2"},
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "invalid azure version_id: v2",
+		}),
+
+		Entry("validation: reserved keys cannot be headed", headTestCase{
+			req: v1.HeadObjectRequest{
+				ExpiresMillis: (2 * time.Minute).Milliseconds(),
+				Targets: []v1.TargetRef{
+					{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "_internal/state"},
+				},
+			},
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "prefix _internal/ is reserved",
+		}),
+	)
+})
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"golang.org/x/text/unicode/norm"
+)
+
+// keyPolicy holds the gateway-wide rules applied to every object key before it is signed.
+type keyPolicy struct {
+	// reservedPrefixes are key prefixes clients may never write to.
+	reservedPrefixes []string
+	// normalizeNFC rewrites keys to Unicode NFC so the same key maps identically on every provider.
+	normalizeNFC bool
+}
+
+func defaultKeyPolicy() keyPolicy {
+	return keyPolicy{
+		reservedPrefixes: []string{"_internal/"},
+		normalizeNFC:     true,
+	}
+}
+
+// applyKeyPolicy rewrites each target's key in place according to the handler's key policy.
+func (h *handler) applyKeyPolicy(targets []v1.TargetRef) error {
+	for i, t := range targets {
+		key, err := h.keys.apply(t.Key)
+		if err != nil {
+			return err
+		}
+		targets[i].Key = key
+	}
+	return nil
+}
+
+// apply checks key against the policy and returns the key that should be signed.
+func (p keyPolicy) apply(key string) (string, error) {
+	if !utf8.ValidString(key) {
+		return "", fmt.Errorf("invalid key: %q. must be valid UTF-8", key)
+	}
+	if p.normalizeNFC {
+		key = norm.NFC.String(key)
+	}
+
+	if strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("invalid key: %s. must not begin with a slash", key)
+	}
+	for _, r := range key {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return "", fmt.Errorf("invalid key: %q. must not contain control characters", key)
+		}
+	}
+	isSep := func(r rune) bool { return r == '/' || r == '\\' }
+	for _, seg := range strings.FieldsFunc(key, isSep) {
+		if seg == "." || seg == ".." {
+			return "", fmt.Errorf("invalid key: %s. must not contain %q path segments", key, seg)
+		}
+	}
+	for _, prefix := range p.reservedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return "", fmt.Errorf("invalid key: %s. prefix %s is reserved", key, prefix)
+		}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keys", func() {
+	type keyTestCase struct {
+		policy          keyPolicy
+		key             string
+		expectKey       string
+		expectErrSubstr string
+	}
+
+	DescribeTable("keyPolicy.apply",
+		func(tc keyTestCase) {
+			got, err := tc.policy.apply(tc.key)
+			if tc.expectErrSubstr == "" {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(got).To(Equal(tc.expectKey))
+			} else {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(tc.expectErrSubstr))
+			}
+		},
+
+		Entry("plain key passes through", keyTestCase{
+			policy:    defaultKeyPolicy(),
+			key:       "reports/2025/q1.json",
+			expectKey: "reports/2025/q1.json",
+		}),
+		Entry("decomposed key is normalized to NFC", keyTestCase{
+			policy:    defaultKeyPolicy(),
+			key:       "cafe\u0301",
+			expectKey: "caf\u00e9",
+		}),
+		Entry("normalization disabled keeps the key as sent", keyTestCase{
+			policy:    keyPolicy{},
+			key:       "cafe\u0301",
+			expectKey: "cafe\u0301",
+		}),
+		Entry("leading slash", keyTestCase{
+			policy:          defaultKeyPolicy(),
+			key:             "/etc/passwd",
+			expectErrSubstr: "must not begin with a slash",
+		}),
+		Entry("parent traversal", keyTestCase{
+			policy:          defaultKeyPolicy(),
+			key:             "a/../b",
+			expectErrSubstr: `must not contain ".." path segments`,
+		}),
+		Entry("backslash traversal", keyTestCase{
+			policy:          defaultKeyPolicy(),
+			key:             `a\..\b`,
+			expectErrSubstr: `must not contain ".." path segments`,
+		}),
+		Entry("current directory segment", keyTestCase{
+			policy:          defaultKeyPolicy(),
+			key:             "./a",
+			expectErrSubstr: `must not contain "." path segments`,
+		}),
+		Entry("dots inside a segment are fine", keyTestCase{
+			policy:    defaultKeyPolicy(),
+			key:       "a/..b/c..",
+			expectKey: "a/..b/c..",
+		}),
+		Entry("control character", keyTestCase{
+			policy:          defaultKeyPolicy(),
+			key:             "a\nb",
+			expectErrSubstr: "must not contain control characters",
+		}),
+		Entry("non-utf-8", keyTestCase{
+			policy:          defaultKeyPolicy(),
+			key:             "a\xc3\x28",
+			expectErrSubstr: "must be valid UTF-8",
+		}),
+		Entry("reserved prefix", keyTestCase{
+			policy:          defaultKeyPolicy(),
+			key:             "_internal/x",
+			expectErrSubstr: "prefix _internal/ is reserved",
+		}),
+		Entry("custom reserved prefix", keyTestCase{
+			policy:          keyPolicy{reservedPrefixes: []string{"system/"}},
+			key:             "system/config",
+			expectErrSubstr: "prefix system/ is reserved",
+		}),
+	)
+})
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+// metadataRule describes how a provider constrains user metadata once keys are lowercased.
+type metadataRule struct {
+	key     *regexp.Regexp
+	keyDesc string
+	// maxTotal is the provider's limit on the summed size of keys and values, in bytes.
+	maxTotal int
+}
+
+// metadataRules follow the user metadata limits of S3 (x-amz-meta-), Azure (x-ms-meta-, C# identifiers)
+// and GCS (x-goog-meta-). A request must satisfy the rules of every provider it targets so that each
+// replica ends up with identical metadata.
+var metadataRules = map[v1.Provider]metadataRule{
+	v1.ProviderAWS: {
+		key:      regexp.MustCompile(`^[a-z0-9!#$%&'*+.^_|~-]+$`),
+		keyDesc:  "an HTTP header token",
+		maxTotal: 2048,
+	},
+	v1.ProviderAzure: {
+		key:      regexp.MustCompile(`^[a-z_][a-z0-9_]*$`),
+		keyDesc:  "a C# identifier (letters, digits and underscores, not starting with a digit)",
+		maxTotal: 8192,
+	},
+	v1.ProviderGCP: {
+		key:      regexp.MustCompile(`^[a-z0-9!#$%&'*+.^_|~-]+$`),
+		keyDesc:  "an HTTP header token",
+		maxTotal: 8192,
+	},
+}
+
+// normalizeMetadata lowercases metadata keys and checks every entry against the rules of each provider
+// in providers. Values must be printable ASCII without surrounding whitespace, which every provider
+// stores and returns byte for byte.
+func normalizeMetadata(md map[string]string, providers []v1.Provider) (map[string]string, error) {
+	if len(md) == 0 {
+		return md, nil
+	}
+
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]string, len(md))
+	total := 0
+	for _, k := range keys {
+		v := md[k]
+		lk := strings.ToLower(k)
+		if lk == tagsMetadataKey {
+			return nil, fmt.Errorf("metadata key %s is reserved for tags", lk)
+		}
+		if _, dup := out[lk]; dup {
+			return nil, fmt.Errorf("duplicate metadata key: %s. keys are case-insensitive", lk)
+		}
+		for _, r := range v {
+			if r < 0x20 || r > 0x7e {
+				return nil, fmt.Errorf("invalid metadata value for %s. must be printable ASCII", lk)
+			}
+		}
+		if strings.TrimSpace(v) != v {
+			return nil, fmt.Errorf("invalid metadata value for %s. must not have leading or trailing whitespace", lk)
+		}
+
+		out[lk] = v
+		total += len(lk) + len(v)
+	}
+
+	for _, p := range providers {
+		rule, ok := metadataRules[p]
+		if !ok {
+			continue
+		}
+		for _, k := range keys {
+			lk := strings.ToLower(k)
+			if !rule.key.MatchString(lk) {
+				return nil, fmt.Errorf("invalid metadata key for %s: %s. must be %s", p, lk, rule.keyDesc)
+			}
+		}
+		if total > rule.maxTotal {
+			return nil, fmt.Errorf("metadata size %d exceeds %s limit of %d bytes", total, p, rule.maxTotal)
+		}
+	}
+
+	return out, nil
+}
+
+// normalizeRequestMetadata normalizes the request-level metadata against every target that inherits it,
+// and each per-target metadata override against that target's provider alone.
+func normalizeRequestMetadata(in *v1.PutObjectRequest) error {
+	var inheriting []v1.TargetRef
+	for i, t := range in.ReplicationTargets {
+		if t.Options == nil || t.Options.Metadata == nil {
+			inheriting = append(inheriting, t)
+			continue
+		}
+
+		md, err := normalizeMetadata(t.Options.Metadata, []v1.Provider{t.Provider})
+		if err != nil {
+			return err
+		}
+		opts := *t.Options
+		opts.Metadata = md
+		in.ReplicationTargets[i].Options = &opts
+	}
+
+	md, err := normalizeMetadata(in.Metadata, targetProviders(inheriting))
+	if err != nil {
+		return err
+	}
+	in.Metadata = md
+	return nil
+}
+
+// targetProviders returns the distinct providers referenced by targets, in first-seen order.
+func targetProviders(targets []v1.TargetRef) []v1.Provider {
+	seen := make(map[v1.Provider]bool, len(targets))
+	var out []v1.Provider
+	for _, t := range targets {
+		if !seen[t.Provider] {
+			seen[t.Provider] = true
+			out = append(out, t.Provider)
+		}
+	}
+	return out
+}
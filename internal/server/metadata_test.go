@@ -0,0 +1,75 @@
+package server
+
+import (
+	"strings"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metadata", func() {
+	type mdTestCase struct {
+		md              map[string]string
+		providers       []v1.Provider
+		expectMD        map[string]string
+		expectErrSubstr string
+	}
+
+	DescribeTable("normalizeMetadata",
+		func(tc mdTestCase) {
+			got, err := normalizeMetadata(tc.md, tc.providers)
+			if tc.expectErrSubstr == "" {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(got).To(Equal(tc.expectMD))
+			} else {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(tc.expectErrSubstr))
+			}
+		},
+
+		Entry("nil metadata", mdTestCase{
+			providers: []v1.Provider{v1.ProviderAWS},
+		}),
+		Entry("keys are lowercased", mdTestCase{
+			md:        map[string]string{"Owner": "team-a", "TRACE_ID": "abc"},
+			providers: []v1.Provider{v1.ProviderAWS, v1.ProviderAzure, v1.ProviderGCP},
+			expectMD:  map[string]string{"owner": "team-a", "trace_id": "abc"},
+		}),
+		Entry("keys that collide after lowercasing", mdTestCase{
+			md:              map[string]string{"Owner": "a", "owner": "b"},
+			providers:       []v1.Provider{v1.ProviderAWS},
+			expectErrSubstr: "duplicate metadata key: owner",
+		}),
+		Entry("hyphenated key is fine for aws alone", mdTestCase{
+			md:        map[string]string{"content-owner": "a"},
+			providers: []v1.Provider{v1.ProviderAWS, v1.ProviderGCP},
+			expectMD:  map[string]string{"content-owner": "a"},
+		}),
+		Entry("hyphenated key is rejected once azure is targeted", mdTestCase{
+			md:              map[string]string{"content-owner": "a"},
+			providers:       []v1.Provider{v1.ProviderAWS, v1.ProviderAzure},
+			expectErrSubstr: "invalid metadata key for azure: content-owner",
+		}),
+		Entry("azure key starting with a digit", mdTestCase{
+			md:              map[string]string{"1st": "a"},
+			providers:       []v1.Provider{v1.ProviderAzure},
+			expectErrSubstr: "must be a C# identifier",
+		}),
+		Entry("non-ascii value", mdTestCase{
+			md:              map[string]string{"owner": "zoë"},
+			providers:       []v1.Provider{v1.ProviderAWS},
+			expectErrSubstr: "must be printable ASCII",
+		}),
+		Entry("value with surrounding whitespace", mdTestCase{
+			md:              map[string]string{"owner": " a "},
+			providers:       []v1.Provider{v1.ProviderAWS},
+			expectErrSubstr: "must not have leading or trailing whitespace",
+		}),
+		Entry("size is checked against the smallest targeted limit", mdTestCase{
+			md:              map[string]string{"a": strings.Repeat("x", 1000), "b": strings.Repeat("x", 1100)},
+			providers:       []v1.Provider{v1.ProviderGCP, v1.ProviderAWS},
+			expectErrSubstr: "exceeds aws limit of 2048 bytes",
+		}),
+	)
+})
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+// nameValidator checks a bucket (or container) and object key against a provider's naming rules.
+type nameValidator func(bucket, key string) error
+
+var nameValidators = map[v1.Provider]nameValidator{
+	v1.ProviderAWS:   validateS3Name,
+	v1.ProviderAzure: validateAzureName,
+	v1.ProviderGCP:   validateGCSName,
+}
+
+var (
+	azureVersionID = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d{1,7})?Z$`)
+	gcsGeneration  = regexp.MustCompile(`^[1-9]\d{0,18}$`)
+	ipLikeName     = regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
+	s3BucketName   = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`)
+	azureContainer = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*[a-z0-9]$`)
+	gcsBucketName  = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*[a-z0-9]$`)
+)
+
+// validateTargetName runs the naming rules registered for the target's provider.
+func validateTargetName(t v1.TargetRef) error {
+	validate, ok := nameValidators[t.Provider]
+	if !ok {
+		return fmt.Errorf("unsupported provider: %s", t.Provider)
+	}
+	return validate(t.Bucket, t.Key)
+}
+
+// validateVersionID checks an object version selector: an opaque S3 version ID, an Azure versionId or
+// snapshot timestamp, or a numeric GCS generation. An empty id selects the current version.
+func validateVersionID(provider v1.Provider, id string) error {
+	if id == "" {
+		return nil
+	}
+
+	switch provider {
+	case v1.ProviderAWS:
+		if len(id) > 1024 {
+			return fmt.Errorf("invalid aws version_id: %s. must be at most 1024 characters", id)
+		}
+		for _, r := range id {
+			if r < 0x21 || r > 0x7e {
+				return fmt.Errorf("invalid aws version_id: %q. must be printable ASCII", id)
+			}
+		}
+	case v1.ProviderAzure:
+		if !azureVersionID.MatchString(id) {
+			return fmt.Errorf("invalid azure version_id: %s. must be an RFC 3339 UTC timestamp", id)
+		}
+	case v1.ProviderGCP:
+		if !gcsGeneration.MatchString(id) {
+			return fmt.Errorf("invalid gcp version_id: %s. must be a numeric generation", id)
+		}
+	}
+	return nil
+}
+
+// validateS3Name follows https://docs.aws.amazon.com/AmazonS3/latest/userguide/bucketnamingrules.html
+func validateS3Name(bucket, key string) error {
+	if len(bucket) < 3 || len(bucket) > 63 {
+		return fmt.Errorf("invalid aws bucket name: %s. must be 3-63 characters", bucket)
+	}
+	if !s3BucketName.MatchString(bucket) {
+		return fmt.Errorf("invalid aws bucket name: %s. must be lowercase letters, digits, dots or hyphens and begin and end with a letter or digit", bucket)
+	}
+	if strings.Contains(bucket, "..") {
+		return fmt.Errorf("invalid aws bucket name: %s. must not contain adjacent dots", bucket)
+	}
+	if ipLikeName.MatchString(bucket) {
+		return fmt.Errorf("invalid aws bucket name: %s. must not be formatted as an IP address", bucket)
+	}
+	for _, p := range []string{"xn--", "sthree-", "amzn-s3-demo-"} {
+		if strings.HasPrefix(bucket, p) {
+			return fmt.Errorf("invalid aws bucket name: %s. must not begin with %q", bucket, p)
+		}
+	}
+	for _, s := range []string{"-s3alias", "--ol-s3", ".mrap", "--x-s3", "--table-s3"} {
+		if strings.HasSuffix(bucket, s) {
+			return fmt.Errorf("invalid aws bucket name: %s. must not end with %q", bucket, s)
+		}
+	}
+
+	return validateKeyBytes(v1.ProviderAWS, key)
+}
+
+// validateAzureName follows https://learn.microsoft.com/en-us/rest/api/storageservices/naming-and-referencing-containers--blobs--and-metadata
+func validateAzureName(container, blob string) error {
+	if len(container) < 3 || len(container) > 63 {
+		return fmt.Errorf("invalid azure container name: %s. must be 3-63 characters", container)
+	}
+	if !azureContainer.MatchString(container) {
+		return fmt.Errorf("invalid azure container name: %s. must be lowercase letters, digits or hyphens and begin and end with a letter or digit", container)
+	}
+	if strings.Contains(container, "--") {
+		return fmt.Errorf("invalid azure container name: %s. must not contain consecutive hyphens", container)
+	}
+
+	if err := validateKeyBytes(v1.ProviderAzure, blob); err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(blob) > 1024 {
+		return fmt.Errorf("invalid azure blob name: %s. must be at most 1024 characters", blob)
+	}
+	if strings.HasSuffix(blob, ".") || strings.HasSuffix(blob, "/") || strings.HasSuffix(blob, "\\") {
+		return fmt.Errorf("invalid azure blob name: %s. must not end with a dot, slash or backslash", blob)
+	}
+	segments := strings.Split(blob, "/")
+	if len(segments) > 254 {
+		return fmt.Errorf("invalid azure blob name: %s. must have at most 254 path segments", blob)
+	}
+	for _, seg := range segments {
+		if seg == "." || seg == ".." {
+			return fmt.Errorf("invalid azure blob name: %s. must not contain %q path segments", blob, seg)
+		}
+	}
+
+	return nil
+}
+
+// validateGCSName follows https://cloud.google.com/storage/docs/buckets#naming and
+// https://cloud.google.com/storage/docs/objects#naming
+func validateGCSName(bucket, object string) error {
+	maxLen := 63
+	if strings.Contains(bucket, ".") {
+		maxLen = 222
+	}
+	if len(bucket) < 3 || len(bucket) > maxLen {
+		return fmt.Errorf("invalid gcp bucket name: %s. must be 3-%d characters", bucket, maxLen)
+	}
+	if !gcsBucketName.MatchString(bucket) {
+		return fmt.Errorf("invalid gcp bucket name: %s. must be lowercase letters, digits, dots, hyphens or underscores and begin and end with a letter or digit", bucket)
+	}
+	for _, c := range strings.Split(bucket, ".") {
+		if c == "" || len(c) > 63 {
+			return fmt.Errorf("invalid gcp bucket name: %s. dot-separated components must be 1-63 characters", bucket)
+		}
+	}
+	if ipLikeName.MatchString(bucket) {
+		return fmt.Errorf("invalid gcp bucket name: %s. must not be formatted as an IP address", bucket)
+	}
+	if strings.HasPrefix(bucket, "goog") || strings.Contains(bucket, "google") {
+		return fmt.Errorf("invalid gcp bucket name: %s. must not begin with \"goog\" or contain \"google\"", bucket)
+	}
+
+	if err := validateKeyBytes(v1.ProviderGCP, object); err != nil {
+		return err
+	}
+	if object == "." || object == ".." {
+		return fmt.Errorf("invalid gcp object name: %s. must not be \".\" or \"..\"", object)
+	}
+	if strings.HasPrefix(object, ".well-known/acme-challenge/") {
+		return fmt.Errorf("invalid gcp object name: %s. must not begin with \".well-known/acme-challenge/\"", object)
+	}
+
+	return nil
+}
+
+// validateKeyBytes applies the rules shared by every provider: 1-1024 bytes of valid UTF-8 with no control characters.
+func validateKeyBytes(provider v1.Provider, key string) error {
+	if key == "" || len(key) > 1024 {
+		return fmt.Errorf("invalid %s key: %s. must be 1-1024 bytes", provider, key)
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("invalid %s key: %q. must be valid UTF-8", provider, key)
+	}
+	for _, r := range key {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return fmt.Errorf("invalid %s key: %q. must not contain control characters", provider, key)
+		}
+	}
+	return nil
+}
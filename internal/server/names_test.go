@@ -0,0 +1,117 @@
+package server
+
+import (
+	"strings"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Names", func() {
+	type nameTestCase struct {
+		target          v1.TargetRef
+		expectErrSubstr string
+	}
+
+	DescribeTable("validateTargetName",
+		func(tc nameTestCase) {
+			err := validateTargetName(tc.target)
+			if tc.expectErrSubstr == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(tc.expectErrSubstr))
+			}
+		},
+
+		Entry("aws: valid", nameTestCase{
+			target: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "my.bucket-1", Key: "a/b/c.json"},
+		}),
+		Entry("aws: too short", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "ab", Key: "k"},
+			expectErrSubstr: "must be 3-63 characters",
+		}),
+		Entry("aws: uppercase", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "MyBucket", Key: "k"},
+			expectErrSubstr: "must be lowercase letters",
+		}),
+		Entry("aws: adjacent dots", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "my..bucket", Key: "k"},
+			expectErrSubstr: "must not contain adjacent dots",
+		}),
+		Entry("aws: ip address", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "192.168.5.4", Key: "k"},
+			expectErrSubstr: "must not be formatted as an IP address",
+		}),
+		Entry("aws: reserved prefix", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "xn--bucket", Key: "k"},
+			expectErrSubstr: `must not begin with "xn--"`,
+		}),
+		Entry("aws: reserved suffix", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket-s3alias", Key: "k"},
+			expectErrSubstr: `must not end with "-s3alias"`,
+		}),
+		Entry("aws: empty key", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: ""},
+			expectErrSubstr: "must be 1-1024 bytes",
+		}),
+		Entry("aws: key too long", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: strings.Repeat("k", 1025)},
+			expectErrSubstr: "must be 1-1024 bytes",
+		}),
+		Entry("aws: control character in key", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "a\x01b"},
+			expectErrSubstr: "must not contain control characters",
+		}),
+		Entry("aws: invalid utf-8 in key", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "a\xffb"},
+			expectErrSubstr: "must be valid UTF-8",
+		}),
+
+		Entry("azure: valid", nameTestCase{
+			target: v1.TargetRef{Provider: v1.ProviderAzure, Bucket: "my-container", Key: "dir/blob.bin"},
+		}),
+		Entry("azure: dots not allowed in container", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAzure, Bucket: "my.container", Key: "k"},
+			expectErrSubstr: "invalid azure container name",
+		}),
+		Entry("azure: consecutive hyphens", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAzure, Bucket: "my--container", Key: "k"},
+			expectErrSubstr: "must not contain consecutive hyphens",
+		}),
+		Entry("azure: trailing dot in blob", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAzure, Bucket: "container", Key: "dir/blob."},
+			expectErrSubstr: "must not end with a dot, slash or backslash",
+		}),
+		Entry("azure: dot-dot segment in blob", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderAzure, Bucket: "container", Key: "a/../b"},
+			expectErrSubstr: `must not contain ".." path segments`,
+		}),
+
+		Entry("gcp: valid with underscores and dots", nameTestCase{
+			target: v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "my_bucket.example.com", Key: "obj"},
+		}),
+		Entry("gcp: dotted component too long", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderGCP, Bucket: strings.Repeat("a", 64) + ".com", Key: "obj"},
+			expectErrSubstr: "dot-separated components must be 1-63 characters",
+		}),
+		Entry("gcp: google in name", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "my-google-bucket", Key: "obj"},
+			expectErrSubstr: `contain "google"`,
+		}),
+		Entry("gcp: dot-dot object", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "bucket", Key: ".."},
+			expectErrSubstr: `must not be "." or ".."`,
+		}),
+		Entry("gcp: acme challenge prefix", nameTestCase{
+			target:          v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "bucket", Key: ".well-known/acme-challenge/x"},
+			expectErrSubstr: "acme-challenge",
+		}),
+
+		Entry("unknown provider", nameTestCase{
+			target:          v1.TargetRef{Provider: "oracle", Bucket: "bucket", Key: "k"},
+			expectErrSubstr: "unsupported provider: oracle",
+		}),
+	)
+})
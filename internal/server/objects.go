@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/verify"
+)
+
+// Alias names a set of buckets that hold replicas of the same objects, listed in order of preference.
+type Alias struct {
+	Name     string         `json:"name"`
+	Replicas []AliasReplica `json:"replicas"`
+}
+
+// AliasReplica is one bucket of an alias. Region is matched against the caller's region, if any.
+type AliasReplica struct {
+	Provider v1.Provider `json:"provider"`
+	Bucket   string      `json:"bucket"`
+	Region   string      `json:"region,omitempty"`
+}
+
+func (r AliasReplica) String() string {
+	return fmt.Sprintf("%s:%s", r.Provider, r.Bucket)
+}
+
+// LoadAliases reads a JSON array of aliases from path.
+func LoadAliases(path string) ([]Alias, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases []Alias
+	if err := json.Unmarshal(b, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to parse aliases: %v", err)
+	}
+
+	seen := make(map[string]bool, len(aliases))
+	for _, a := range aliases {
+		switch {
+		case a.Name == "":
+			return nil, fmt.Errorf("alias has no name")
+		case seen[a.Name]:
+			return nil, fmt.Errorf("duplicate alias: %s", a.Name)
+		case len(a.Replicas) == 0:
+			return nil, fmt.Errorf("alias %s has no replicas", a.Name)
+		}
+		seen[a.Name] = true
+
+		for _, r := range a.Replicas {
+			if err := validateTargetName(v1.TargetRef{Provider: r.Provider, Bucket: r.Bucket, Key: "k"}); err != nil {
+				return nil, fmt.Errorf("alias %s: %v", a.Name, err)
+			}
+		}
+	}
+	return aliases, nil
+}
+
+// aliasConfig resolves /v1/objects/{alias}/{key} to a replica. Each candidate is checked with a presigned
+// HEAD before the caller is redirected to it; a replica whose check fails, rather than reporting the object
+// missing, is marked unhealthy and tried last until the cooldown passes.
+type aliasConfig struct {
+	aliases  map[string]Alias
+	replicas *verify.ReplicationVerifier
+	ttl      time.Duration
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	unhealthy map[string]time.Time
+}
+
+func (a *aliasConfig) healthy(r AliasReplica) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return time.Now().After(a.unhealthy[r.String()])
+}
+
+func (a *aliasConfig) markUnhealthy(r AliasReplica) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.unhealthy[r.String()] = time.Now().Add(a.cooldown)
+}
+
+// candidates orders the replicas of alias: healthy before unhealthy, then those in region before the
+// rest, then in configured order.
+func (a *aliasConfig) candidates(alias Alias, region string) []AliasReplica {
+	out := append([]AliasReplica(nil), alias.Replicas...)
+	healthy := make(map[string]bool, len(out))
+	for _, r := range out {
+		healthy[r.String()] = a.healthy(r)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		hi, hj := healthy[out[i].String()], healthy[out[j].String()]
+		if hi != hj {
+			return hi
+		}
+		ri, rj := region != "" && out[i].Region == region, region != "" && out[j].Region == region
+		return ri && !rj
+	})
+	return out
+}
+
+// handleObjectRedirect handles http.MethodGet to /v1/objects/{alias}/{key}. The caller's region is read
+// from ?region= or the X-Bsync-Region header.
+func (h *handler) handleObjectRedirect(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	alias, ok := h.aliases.aliases[vars["alias"]]
+	if !ok {
+		http.Error(w, fmt.Sprintf("alias not found: %s", vars["alias"]), http.StatusNotFound)
+		return
+	}
+
+	key, err := h.keys.apply(vars["key"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	region := r.URL.Query().Get("region")
+	if region == "" {
+		region = r.Header.Get("X-Bsync-Region")
+	}
+
+	var failed []string
+	for _, rep := range h.aliases.candidates(alias, region) {
+		t := v1.TargetRef{Provider: rep.Provider, Bucket: rep.Bucket, Key: key}
+		if err := validateTargetName(t); err != nil {
+			http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+			return
+		}
+		presigner, ok := h.signers[rep.Provider]
+		if !ok {
+			failed = append(failed, fmt.Sprintf("%s: provider not configured", rep))
+			continue
+		}
+
+		st := h.aliases.replicas.Check(ctx, verify.Job{Targets: []v1.TargetRef{t}})
+		if rs := st.Replicas[0]; rs.Error != "" {
+			h.aliases.markUnhealthy(rep)
+			failed = append(failed, fmt.Sprintf("%s: %s", rep, rs.Error))
+			continue
+		} else if !rs.Exists {
+			continue
+		}
+
+		u, err := presigner.PresignGet(ctx, t.Bucket, t.Key, presign.NewGetOptions(presign.WithGetTTL(h.aliases.ttl)))
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: presign failed: %v", rep, err))
+			continue
+		}
+
+		// The URL expires, so the redirect must not be cached past it.
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, u.URL, http.StatusTemporaryRedirect)
+		return
+	}
+
+	if len(failed) > 0 {
+		http.Error(w, fmt.Sprintf("no replica available for %s/%s: %v", alias.Name, key, failed), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, "object not found", http.StatusNotFound)
+}
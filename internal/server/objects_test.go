@@ -0,0 +1,224 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/verify"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("ObjectRedirect", func() {
+	var (
+		aws    *mockPresigner
+		gcp    *mockPresigner
+		hnd    *handler
+		srv    *httptest.Server
+		mu     sync.Mutex
+		status map[string]int
+		heads  map[string]int
+	)
+
+	alias := Alias{
+		Name: "photos",
+		Replicas: []AliasReplica{
+			{Provider: v1.ProviderAWS, Bucket: "photos-use1", Region: "us-east-1"},
+			{Provider: v1.ProviderGCP, Bucket: "photos-euw1", Region: "europe-west1"},
+		},
+	}
+
+	BeforeEach(func() {
+		aws = &mockPresigner{}
+		gcp = &mockPresigner{}
+		status = map[string]int{}
+		heads = map[string]int{}
+
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			heads[r.URL.Path]++
+			code, ok := status[r.URL.Path]
+			if !ok {
+				code = http.StatusNotFound
+			}
+			w.WriteHeader(code)
+		}))
+		DeferCleanup(srv.Close)
+
+		hnd = &handler{
+			signers: presign.Registry{
+				v1.ProviderAWS: aws,
+				v1.ProviderGCP: gcp,
+			},
+			keys: defaultKeyPolicy(),
+		}
+		WithAliases([]Alias{alias}, verify.WithReplicationHTTPClient(srv.Client()))(hnd)
+
+		for _, rep := range alias.Replicas {
+			m := aws
+			if rep.Provider == v1.ProviderGCP {
+				m = gcp
+			}
+			m.
+				On("PresignHead", mock.Anything, rep.Bucket, mock.Anything, mock.Anything).
+				Return(&v1.PresignedUrl{URL: srv.URL + "/" + rep.Bucket, Method: http.MethodHead}, nil).
+				Maybe()
+			m.
+				On("PresignGet", mock.Anything, rep.Bucket, mock.Anything, mock.Anything).
+				Return(&v1.PresignedUrl{URL: "https://signed/" + rep.Bucket, Method: http.MethodGet}, nil).
+				Maybe()
+		}
+	})
+
+	get := func(aliasName, key string, hdr http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/objects/"+aliasName+"/"+key, nil)
+		for k, v := range hdr {
+			req.Header[k] = v
+		}
+		req = mux.SetURLVars(req, map[string]string{"alias": aliasName, "key": key})
+		rr := httptest.NewRecorder()
+		hnd.handleObjectRedirect(rr, req)
+		return rr
+	}
+
+	type redirectTestCase struct {
+		status         map[string]int
+		region         string
+		expectCode     int
+		expectLocation string
+		expectBody     string
+	}
+
+	DescribeTable("handleObjectRedirect",
+		func(tc redirectTestCase) {
+			for path, code := range tc.status {
+				status[path] = code
+			}
+
+			hdr := http.Header{}
+			if tc.region != "" {
+				hdr.Set("X-Bsync-Region", tc.region)
+			}
+			rr := get("photos", "2026/cat.png", hdr)
+
+			Expect(rr.Code).To(Equal(tc.expectCode), "body: %s", rr.Body.String())
+			if tc.expectLocation != "" {
+				Expect(rr.Header().Get("Location")).To(Equal(tc.expectLocation))
+				Expect(rr.Header().Get("Cache-Control")).To(Equal("no-store"))
+			}
+			if tc.expectBody != "" {
+				Expect(rr.Body.String()).To(ContainSubstring(tc.expectBody))
+			}
+		},
+
+		Entry("prefers the first replica", redirectTestCase{
+			status:         map[string]int{"/photos-use1": 200, "/photos-euw1": 200},
+			expectCode:     http.StatusTemporaryRedirect,
+			expectLocation: "https://signed/photos-use1",
+		}),
+		Entry("prefers a replica in the caller's region", redirectTestCase{
+			status:         map[string]int{"/photos-use1": 200, "/photos-euw1": 200},
+			region:         "europe-west1",
+			expectCode:     http.StatusTemporaryRedirect,
+			expectLocation: "https://signed/photos-euw1",
+		}),
+		Entry("falls back when the preferred replica is missing the object", redirectTestCase{
+			status:         map[string]int{"/photos-euw1": 200},
+			expectCode:     http.StatusTemporaryRedirect,
+			expectLocation: "https://signed/photos-euw1",
+		}),
+		Entry("falls back when the preferred replica fails", redirectTestCase{
+			status:         map[string]int{"/photos-use1": 503, "/photos-euw1": 200},
+			expectCode:     http.StatusTemporaryRedirect,
+			expectLocation: "https://signed/photos-euw1",
+		}),
+		Entry("404 when no replica has the object", redirectTestCase{
+			expectCode: http.StatusNotFound,
+			expectBody: "object not found",
+		}),
+		Entry("503 when no replica can be checked", redirectTestCase{
+			status:     map[string]int{"/photos-use1": 500, "/photos-euw1": 500},
+			expectCode: http.StatusServiceUnavailable,
+			expectBody: "no replica available for photos/2026/cat.png",
+		}),
+	)
+
+	It("tries a replica that failed last until its cooldown passes", func() {
+		status["/photos-use1"] = http.StatusInternalServerError
+		status["/photos-euw1"] = http.StatusOK
+
+		Expect(get("photos", "k", nil).Code).To(Equal(http.StatusTemporaryRedirect))
+		status["/photos-use1"] = http.StatusOK
+		rr := get("photos", "k", nil)
+
+		Expect(rr.Header().Get("Location")).To(Equal("https://signed/photos-euw1"))
+		Expect(heads["/photos-use1"]).To(Equal(1))
+
+		hnd.aliases.unhealthy = map[string]time.Time{}
+		rr = get("photos", "k", nil)
+		Expect(rr.Header().Get("Location")).To(Equal("https://signed/photos-use1"))
+	})
+
+	It("routes keys exactly as sent", func() {
+		status["/photos-use1"] = http.StatusOK
+		route := func(key string) *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			hnd.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/objects/photos/"+key, nil))
+			return rr
+		}
+
+		for _, key := range []string{"a//b.txt", "dir/"} {
+			rr := route(key)
+			Expect(rr.Code).To(Equal(http.StatusTemporaryRedirect), "key %s: %s", key, rr.Body.String())
+			aws.AssertCalled(GinkgoT(), "PresignGet", mock.Anything, "photos-use1", key, mock.Anything)
+		}
+		// Dot segments are refused by the key policy rather than cleaned into another key.
+		rr := route("a/../b.txt")
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		Expect(rr.Body.String()).To(ContainSubstring("must not contain"))
+	})
+
+	It("returns 404 for unknown aliases", func() {
+		rr := get("videos", "k", nil)
+
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+		Expect(rr.Body.String()).To(ContainSubstring("alias not found: videos"))
+	})
+
+	It("applies the key policy", func() {
+		rr := get("photos", "_internal/secret", nil)
+
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		aws.AssertNotCalled(GinkgoT(), "PresignHead", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	DescribeTable("LoadAliases",
+		func(body, expectErr string) {
+			path := filepath.Join(GinkgoT().TempDir(), "aliases.json")
+			Expect(os.WriteFile(path, []byte(body), 0o600)).To(Succeed())
+
+			aliases, err := LoadAliases(path)
+			if expectErr != "" {
+				Expect(err).To(MatchError(ContainSubstring(expectErr)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(aliases).To(HaveLen(1))
+		},
+
+		Entry("valid", `[{"name":"photos","replicas":[{"provider":"aws","bucket":"photos-use1","region":"us-east-1"}]}]`, ""),
+		Entry("no name", `[{"replicas":[{"provider":"aws","bucket":"photos-use1"}]}]`, "alias has no name"),
+		Entry("duplicate", `[{"name":"a","replicas":[{"provider":"aws","bucket":"bucket-1"}]},{"name":"a","replicas":[{"provider":"aws","bucket":"bucket-1"}]}]`, "duplicate alias: a"),
+		Entry("no replicas", `[{"name":"a"}]`, "alias a has no replicas"),
+		Entry("bad bucket", `[{"name":"a","replicas":[{"provider":"aws","bucket":"Bad_Bucket"}]}]`, "alias a:"),
+	)
+})
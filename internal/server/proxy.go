@@ -0,0 +1,300 @@
+package server
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/verify"
+)
+
+// proxyConfig bounds gateway-managed uploads. Memory use per upload is one chunk, however many targets
+// there are and however large the object is.
+type proxyConfig struct {
+	client    *http.Client
+	chunkSize int
+	maxSize   int64
+}
+
+// ProxyOption mutates the proxy upload configuration.
+type ProxyOption func(*proxyConfig)
+
+// WithProxyHTTPClient sets the client used to PUT to the presigned URLs.
+func WithProxyHTTPClient(c *http.Client) ProxyOption {
+	return func(p *proxyConfig) { p.client = c }
+}
+
+// WithProxyChunkSize sets how many bytes are read from the client before they are written to every target.
+func WithProxyChunkSize(n int) ProxyOption {
+	return func(p *proxyConfig) { p.chunkSize = n }
+}
+
+// WithProxyMaxSize sets the largest content_length a proxy upload may declare.
+func WithProxyMaxSize(n int64) ProxyOption {
+	return func(p *proxyConfig) { p.maxSize = n }
+}
+
+const (
+	// maxProxyRequestSize bounds the JSON part of a proxy upload.
+	maxProxyRequestSize = 1 << 20
+	// defaultProxyExpiry is the URL lifetime used when a proxy upload does not set expires_ms.
+	defaultProxyExpiry = 5 * time.Minute
+)
+
+// handleProxyUpload handles http.MethodPost to /v1/proxy/put. The body is multipart/form-data: a
+// "request" part holding a PutObjectRequest, followed by a "body" part holding the object.
+func (h *handler) handleProxyUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	in, err := readProxyRequest(mr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case in.ContentLength <= 0:
+		http.Error(w, "failed to validate request: content_length is required for proxy uploads", http.StatusBadRequest)
+		return
+	case in.ContentLength > h.proxy.maxSize:
+		http.Error(w, fmt.Sprintf("failed to validate request: content_length %d exceeds %d", in.ContentLength, h.proxy.maxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body, err := mr.NextPart()
+	if err == nil && body.FormName() != "body" {
+		err = fmt.Errorf("expected part %q, got %q", "body", body.FormName())
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// The gateway uses the URLs right away, so the client need not pick an expiry.
+	if in.ExpiresMillis == 0 {
+		in.ExpiresMillis = defaultProxyExpiry.Milliseconds()
+	}
+	signed, status, err := h.presignPut(ctx, &in)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	n, sum, results, streamErr := h.proxy.fanOut(ctx, in, signed.Targets, body)
+	resp := v1.ProxyUploadResponse{
+		UploadID:       signed.UploadID,
+		VerificationID: signed.VerificationID,
+		ContentLength:  n,
+		ContentMD5:     sum,
+		Results:        results,
+	}
+
+	if h.uploads != nil {
+		session, err := h.uploads.Get(ctx, signed.UploadID)
+		if err == nil {
+			session, err = h.completeUpload(ctx, session, results)
+		}
+		if err != nil {
+			writeUploadError(w, signed.UploadID, err)
+			return
+		}
+		resp.Upload = &session
+	}
+
+	if streamErr != nil {
+		http.Error(w, fmt.Sprintf("failed to read body: %v", streamErr), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func readProxyRequest(mr *multipart.Reader) (v1.PutObjectRequest, error) {
+	var in v1.PutObjectRequest
+
+	part, err := mr.NextPart()
+	if err != nil {
+		return in, err
+	}
+	defer func() { _ = part.Close() }()
+	if part.FormName() != "request" {
+		return in, fmt.Errorf("expected part %q, got %q", "request", part.FormName())
+	}
+
+	err = json.NewDecoder(io.LimitReader(part, maxProxyRequestSize)).Decode(&in)
+	return in, err
+}
+
+// fanOutTarget is one replica PUT fed through a pipe.
+type fanOutTarget struct {
+	url    v1.PresignedUrl
+	pw     *io.PipeWriter
+	live   bool
+	result v1.UploadResult
+	md5    string
+	done   chan struct{}
+}
+
+// fanOut streams body to every url at once. Each chunk is written to all targets before the next one is
+// read, so memory stays at one chunk and the client is slowed to the pace of the slowest target. A target
+// that fails is dropped without stopping the others. Once the body is done, each target's result is
+// checked against the MD5 of the bytes received and against the declared content_md5. fanOut returns the
+// number of bytes and the base64 MD5 it received, and an error if the body did not match content_length
+// or could not be read; every target then fails.
+func (p *proxyConfig) fanOut(ctx context.Context, in v1.PutObjectRequest, urls []v1.PresignedUrl, body io.Reader) (int64, string, []v1.UploadResult, error) {
+	refs := uploadTargets(primaryTargets(in.ReplicationTargets))
+	targets := make([]*fanOutTarget, len(urls))
+	for i, u := range urls {
+		pr, pw := io.Pipe()
+		t := &fanOutTarget{
+			url:    u,
+			pw:     pw,
+			live:   true,
+			result: v1.UploadResult{TargetRef: refs[i]},
+			done:   make(chan struct{}),
+		}
+		targets[i] = t
+		go p.put(ctx, in, t, pr)
+	}
+
+	hash := md5.New()
+	buf := make([]byte, p.chunkSize)
+	var (
+		n       int64
+		readErr error
+	)
+	for {
+		k, err := io.ReadFull(body, buf)
+		if k > 0 {
+			n += int64(k)
+			if n > in.ContentLength {
+				readErr = fmt.Errorf("body exceeds content_length %d", in.ContentLength)
+				break
+			}
+			hash.Write(buf[:k])
+			writeAll(targets, buf[:k])
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	if readErr == nil && n != in.ContentLength {
+		readErr = fmt.Errorf("body has %d bytes, content_length %d", n, in.ContentLength)
+	}
+
+	for _, t := range targets {
+		if readErr != nil {
+			t.pw.CloseWithError(readErr)
+		} else {
+			_ = t.pw.Close()
+		}
+	}
+
+	sum := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	results := make([]v1.UploadResult, len(targets))
+	for i, t := range targets {
+		<-t.done
+
+		res := t.result
+		if res.Error == "" && res.StatusCode/100 == 2 && readErr == nil {
+			switch {
+			case in.ContentMD5 != "" && in.ContentMD5 != sum:
+				res.Error = fmt.Sprintf("content md5 %s, expected %s", sum, in.ContentMD5)
+			case t.md5 != "" && t.md5 != sum:
+				res.Error = fmt.Sprintf("stored md5 %s, sent %s", t.md5, sum)
+			}
+		}
+		if readErr != nil && res.Error == "" {
+			res.Error = readErr.Error()
+		}
+		results[i] = res
+	}
+	return n, sum, results, readErr
+}
+
+// writeAll writes chunk to every live target concurrently and waits for all of them. A pipe write only
+// returns once the PUT has consumed the chunk, which is what pushes back on the client.
+func writeAll(targets []*fanOutTarget, chunk []byte) {
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		if !t.live {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := t.pw.Write(chunk); err != nil {
+				t.live = false
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// put sends one replica PUT with pr as its body and records the outcome in t.
+func (p *proxyConfig) put(ctx context.Context, in v1.PutObjectRequest, t *fanOutTarget, pr *io.PipeReader) {
+	defer close(t.done)
+
+	fail := func(err error) {
+		t.result.Error = err.Error()
+		// Unblock the writer if the request ended before consuming the body.
+		pr.CloseWithError(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.url.URL, pr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	req.ContentLength = in.ContentLength
+	for k, vals := range t.url.HeaderValues {
+		for _, val := range vals {
+			req.Header.Add(k, val)
+		}
+	}
+	if in.ContentMD5 != "" && req.Header.Get("Content-MD5") == "" {
+		req.Header.Set("Content-MD5", in.ContentMD5)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer func() { _ = res.Body.Close() }()
+	pr.CloseWithError(errors.New("target responded"))
+
+	t.result.StatusCode = res.StatusCode
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		t.result.Error = strings.TrimSpace(fmt.Sprintf("%s %s", res.Status, msg))
+		return
+	}
+	if t.url.VersionHeader != "" {
+		t.result.VersionID = res.Header.Get(t.url.VersionHeader)
+	}
+	if sum, ok := verify.ReportedMD5(t.result.TargetRef.Provider, res.Header); ok {
+		t.md5 = sum
+	}
+}
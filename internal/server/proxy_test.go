@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"github.com/jordanharrington/bsync/internal/verify"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+// emptyContentMD5 is the base64 MD5 of an empty payload.
+const emptyContentMD5 = "1B2M2Y8AsgTpgAmY7PhCfg=="
+
+var _ = Describe("ProxyUpload", func() {
+	var (
+		aws      *mockPresigner
+		hnd      *handler
+		srv      *httptest.Server
+		mu       sync.Mutex
+		stored   map[string][]byte
+		rejected map[string]int
+		badETag  map[string]bool
+	)
+
+	primary := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}
+	backup := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1"}
+	payload := strings.Repeat("0123456789", 10)
+	sum := md5.Sum([]byte(payload))
+	payloadMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+	BeforeEach(func() {
+		aws = &mockPresigner{}
+		stored = map[string][]byte{}
+		rejected = map[string]int{}
+		badETag = map[string]bool{}
+
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch r.Method {
+			case http.MethodPut:
+				if code, ok := rejected[r.URL.Path]; ok {
+					w.WriteHeader(code)
+					_, _ = io.WriteString(w, "AccessDenied")
+					return
+				}
+				Expect(r.ContentLength).To(Equal(int64(len(payload))))
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				stored[r.URL.Path] = b
+
+				etag := md5.Sum(b)
+				if badETag[r.URL.Path] {
+					etag = md5.Sum([]byte("something else"))
+				}
+				w.Header().Set("ETag", `"`+hex.EncodeToString(etag[:])+`"`)
+				w.Header().Set("x-amz-version-id", "v-"+r.URL.Path)
+			case http.MethodHead:
+				b, ok := stored[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+			}
+		}))
+		DeferCleanup(srv.Close)
+
+		hnd = &handler{
+			signers: presign.Registry{
+				v1.ProviderAWS: aws,
+			},
+			keys: defaultKeyPolicy(),
+		}
+		WithProxyUploads(WithProxyHTTPClient(srv.Client()), WithProxyChunkSize(16), WithProxyMaxSize(1024))(hnd)
+
+		for _, t := range []v1.TargetRef{primary, backup} {
+			path := "/" + t.Bucket + "/" + t.Key
+			aws.
+				On("PresignPut", mock.Anything, t.Bucket, t.Key, mock.Anything).
+				Return(&v1.PresignedUrl{TargetRef: t, URL: srv.URL + path, Method: http.MethodPut, VersionHeader: "x-amz-version-id"}, nil).
+				Maybe()
+			aws.
+				On("PresignHead", mock.Anything, t.Bucket, t.Key, mock.Anything).
+				Return(&v1.PresignedUrl{TargetRef: t, URL: srv.URL + path, Method: http.MethodHead}, nil).
+				Maybe()
+		}
+	})
+
+	upload := func(in v1.PutObjectRequest, body string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		req, _ := mw.CreateFormField("request")
+		Expect(json.NewEncoder(req).Encode(in)).To(Succeed())
+		part, _ := mw.CreateFormFile("body", "object")
+		_, _ = io.WriteString(part, body)
+		Expect(mw.Close()).To(Succeed())
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/proxy/put", &buf)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		rr := httptest.NewRecorder()
+		hnd.handleProxyUpload(rr, r)
+		return rr
+	}
+
+	request := func() v1.PutObjectRequest {
+		return v1.PutObjectRequest{
+			ContentType:        "text/plain",
+			ContentLength:      int64(len(payload)),
+			ReplicationTargets: []v1.TargetRef{primary, backup},
+		}
+	}
+
+	decode := func(rr *httptest.ResponseRecorder) v1.ProxyUploadResponse {
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+		var resp v1.ProxyUploadResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		return resp
+	}
+
+	It("streams one body to every target", func() {
+		resp := decode(upload(request(), payload))
+
+		Expect(resp.ContentLength).To(Equal(int64(len(payload))))
+		Expect(resp.ContentMD5).To(Equal(payloadMD5))
+		Expect(resp.Results).To(ConsistOf(
+			v1.UploadResult{TargetRef: primary, StatusCode: http.StatusOK, VersionID: "v-/bsync-b1/k1"},
+			v1.UploadResult{TargetRef: backup, StatusCode: http.StatusOK, VersionID: "v-/bsync-b2/k1"},
+		))
+		Expect(string(stored["/bsync-b1/k1"])).To(Equal(payload))
+		Expect(string(stored["/bsync-b2/k1"])).To(Equal(payload))
+	})
+
+	It("keeps streaming to the other targets when one rejects the upload", func() {
+		rejected["/bsync-b2/k1"] = http.StatusForbidden
+
+		resp := decode(upload(request(), payload))
+
+		Expect(resp.Results[0].Error).To(BeEmpty())
+		Expect(resp.Results[1].StatusCode).To(Equal(http.StatusForbidden))
+		Expect(resp.Results[1].Error).To(Equal("403 Forbidden AccessDenied"))
+		Expect(string(stored["/bsync-b1/k1"])).To(Equal(payload))
+	})
+
+	It("flags a target whose stored checksum differs from what was sent", func() {
+		badETag["/bsync-b1/k1"] = true
+
+		resp := decode(upload(request(), payload))
+
+		Expect(resp.Results[0].Error).To(HavePrefix("stored md5 "))
+		Expect(resp.Results[1].Error).To(BeEmpty())
+	})
+
+	It("flags every target when the body does not match the declared content_md5", func() {
+		in := request()
+		in.ContentMD5 = emptyContentMD5
+
+		resp := decode(upload(in, payload))
+
+		Expect(resp.Results).To(HaveEach(HaveField("Error", "content md5 "+payloadMD5+", expected "+emptyContentMD5)))
+	})
+
+	It("fails every target when the body is shorter than content_length", func() {
+		rr := upload(request(), payload[:40])
+
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		Expect(rr.Body.String()).To(ContainSubstring("body has 40 bytes, content_length 100"))
+		Expect(stored).To(BeEmpty())
+	})
+
+	DescribeTable("rejects",
+		func(in v1.PutObjectRequest, expectCode int, expectMsg string) {
+			rr := upload(in, payload)
+
+			Expect(rr.Code).To(Equal(expectCode))
+			Expect(rr.Body.String()).To(ContainSubstring(expectMsg))
+			aws.AssertNotCalled(GinkgoT(), "PresignPut", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		},
+
+		Entry("a missing content_length", v1.PutObjectRequest{
+			ReplicationTargets: []v1.TargetRef{primary},
+		}, http.StatusBadRequest, "content_length is required for proxy uploads"),
+		Entry("an object over the size limit", v1.PutObjectRequest{
+			ContentLength:      2048,
+			ReplicationTargets: []v1.TargetRef{primary},
+		}, http.StatusRequestEntityTooLarge, "content_length 2048 exceeds 1024"),
+	)
+
+	It("closes the upload session with the streamed results", func() {
+		store := uploads.NewMemoryStore()
+		WithUploadSessions(store, verify.WithReplicationHTTPClient(srv.Client()))(hnd)
+
+		resp := decode(upload(request(), payload))
+
+		Expect(resp.UploadID).NotTo(BeEmpty())
+		Expect(resp.Upload).NotTo(BeNil())
+		Expect(resp.Upload.State).To(Equal(v1.UploadCommitted))
+
+		s, err := store.Get(context.Background(), resp.UploadID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Targets).To(ContainElement(HaveField("VersionID", "v-/bsync-b1/k1")))
+	})
+})
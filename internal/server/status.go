@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/uploads"
+)
+
+// handleObjectStatus handles http.MethodGet to /v1/objects/status. The object is selected either by
+// ?upload_id= or by ?provider=&bucket=&key=, which resolves to the newest upload that wrote that target.
+func (h *handler) handleObjectStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	var (
+		session v1.UploadSession
+		err     error
+	)
+	if id := q.Get("upload_id"); id != "" {
+		if q.Get("provider") != "" || q.Get("bucket") != "" || q.Get("key") != "" {
+			http.Error(w, "failed to validate request: upload_id cannot be combined with provider, bucket or key", http.StatusBadRequest)
+			return
+		}
+		session, err = h.uploads.Get(ctx, id)
+	} else {
+		t := []v1.TargetRef{{
+			Provider: v1.Provider(q.Get("provider")),
+			Bucket:   q.Get("bucket"),
+			Key:      q.Get("key"),
+		}}
+		if err := h.applyKeyPolicy(t); err != nil {
+			http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateTargetName(t[0]); err != nil {
+			http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+			return
+		}
+		session, err = h.uploads.FindByTarget(ctx, t[0])
+	}
+	if errors.Is(err, uploads.ErrNotFound) {
+		http.Error(w, "object not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read object status: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(objectStatus(session))
+}
+
+// objectStatus reports one replica per target. Until the first verification, each replica carries only
+// its target and replication_state is empty.
+func objectStatus(s v1.UploadSession) v1.ObjectStatusResponse {
+	replicas := s.Replicas
+	if len(replicas) == 0 {
+		replicas = make([]v1.ReplicaStatus, len(s.Targets))
+		for i, t := range s.Targets {
+			replicas[i] = v1.ReplicaStatus{TargetRef: t}
+		}
+	}
+
+	return v1.ObjectStatusResponse{
+		UploadID:    s.ID,
+		UploadState: s.State,
+		Replication: s.Replication,
+		VerifiedAt:  s.VerifiedAt,
+		Replicas:    replicas,
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ObjectStatus", func() {
+	var hnd *handler
+
+	aws := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "caf\u00e9.txt"}
+	gcp := v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "bsync-b2", Key: "caf\u00e9.txt"}
+	verified := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		ctx := context.Background()
+		store := uploads.NewMemoryStore()
+		hnd = &handler{
+			signers: presign.Registry{},
+			keys:    defaultKeyPolicy(),
+		}
+		WithUploadSessions(store)(hnd)
+
+		Expect(store.Create(ctx, v1.UploadSession{ID: "u1", State: v1.UploadPending, Targets: []v1.TargetRef{aws, gcp}})).To(Succeed())
+		Expect(store.Create(ctx, v1.UploadSession{ID: "u2", State: v1.UploadCommitted, Targets: []v1.TargetRef{aws}})).To(Succeed())
+		Expect(uploads.RecordStatus(ctx, store, v1.ReplicationStatus{
+			VerificationID: "u1",
+			State:          v1.ReplicationMismatched,
+			Replicas: []v1.ReplicaStatus{
+				{TargetRef: aws, Exists: true},
+				{TargetRef: gcp, Exists: true, Mismatch: "content length 3, expected 4"},
+			},
+			CheckedAt: verified,
+		})).To(Succeed())
+	})
+
+	type statusTestCase struct {
+		query           string
+		expectHTTP      int
+		expectUpload    string
+		expectState     v1.ReplicationState
+		expectReplicas  int
+		expectErrSubstr string
+	}
+
+	DescribeTable("GetStatus",
+		func(tc statusTestCase) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/objects/status?"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			hnd.handleObjectStatus(rr, req)
+
+			Expect(rr.Code).To(Equal(tc.expectHTTP), "body: %s", rr.Body.String())
+			if tc.expectErrSubstr != "" {
+				Expect(rr.Body.String()).To(ContainSubstring(tc.expectErrSubstr))
+				return
+			}
+
+			var resp v1.ObjectStatusResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+			Expect(resp.UploadID).To(Equal(tc.expectUpload))
+			Expect(resp.Replication).To(Equal(tc.expectState))
+			Expect(resp.Replicas).To(HaveLen(tc.expectReplicas))
+		},
+
+		Entry("by upload id, with discrepancies", statusTestCase{
+			query:          "upload_id=u1",
+			expectHTTP:     http.StatusOK,
+			expectUpload:   "u1",
+			expectState:    v1.ReplicationMismatched,
+			expectReplicas: 2,
+		}),
+		Entry("by target resolves to the newest upload, before verification", statusTestCase{
+			query:          "provider=aws&bucket=bsync-b1&key=cafe%CC%81.txt",
+			expectHTTP:     http.StatusOK,
+			expectUpload:   "u2",
+			expectReplicas: 1,
+		}),
+		Entry("unknown upload", statusTestCase{
+			query:           "upload_id=nope",
+			expectHTTP:      http.StatusNotFound,
+			expectErrSubstr: "object not found",
+		}),
+		Entry("upload id and target together", statusTestCase{
+			query:           "upload_id=u1&provider=aws",
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "cannot be combined",
+		}),
+		Entry("invalid target", statusTestCase{
+			query:           "provider=aws&bucket=B&key=k",
+			expectHTTP:      http.StatusBadRequest,
+			expectErrSubstr: "invalid aws bucket name",
+		}),
+	)
+
+	It("reports verification details per replica", func() {
+		req := httptest.NewRequest(http.MethodGet, "/v1/objects/status?upload_id=u1", nil)
+		rr := httptest.NewRecorder()
+		hnd.handleObjectStatus(rr, req)
+
+		var resp v1.ObjectStatusResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.VerifiedAt).NotTo(BeNil())
+		Expect(*resp.VerifiedAt).To(BeTemporally("==", verified))
+		Expect(resp.Replicas[1].Mismatch).To(Equal("content length 3, expected 4"))
+	})
+})
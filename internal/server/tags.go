@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"unicode/utf8"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+// tagsMetadataKey is the metadata entry that carries tags on providers without native object tagging.
+// The value uses the same URL-encoded form as x-amz-tagging.
+const tagsMetadataKey = "bsync_tags"
+
+// tagRule describes how a provider constrains object tags.
+type tagRule struct {
+	maxTags  int
+	maxKey   int
+	maxValue int
+	chars    *regexp.Regexp
+	native   bool
+}
+
+// tagRules follow S3 object tagging (x-amz-tagging) and Azure blob index tags (x-ms-tags). GCS has no
+// object tags, so they are folded into metadata under tagsMetadataKey instead.
+var tagRules = map[v1.Provider]tagRule{
+	v1.ProviderAWS: {
+		maxTags:  10,
+		maxKey:   128,
+		maxValue: 256,
+		chars:    regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`),
+		native:   true,
+	},
+	v1.ProviderAzure: {
+		maxTags:  10,
+		maxKey:   128,
+		maxValue: 256,
+		chars:    regexp.MustCompile(`^[a-zA-Z0-9 +\-./:=_]*$`),
+		native:   true,
+	},
+	v1.ProviderGCP: {
+		maxTags:  10,
+		maxKey:   128,
+		maxValue: 256,
+		chars:    regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`),
+	},
+}
+
+// validateTags checks tags against the rules of provider.
+func validateTags(provider v1.Provider, tags map[string]string) error {
+	rule, ok := tagRules[provider]
+	if !ok || len(tags) == 0 {
+		return nil
+	}
+
+	if len(tags) > rule.maxTags {
+		return fmt.Errorf("too many tags for %s (max %d)", provider, rule.maxTags)
+	}
+	for k, v := range tags {
+		if k == "" || utf8.RuneCountInString(k) > rule.maxKey {
+			return fmt.Errorf("invalid tag key: %s. must be 1-%d characters", k, rule.maxKey)
+		}
+		if utf8.RuneCountInString(v) > rule.maxValue {
+			return fmt.Errorf("tag value too long: %s (max %d characters)", k, rule.maxValue)
+		}
+		if !rule.chars.MatchString(k) || !rule.chars.MatchString(v) {
+			return fmt.Errorf("invalid tag %s=%s. contains characters not allowed by %s", k, v, provider)
+		}
+	}
+	return nil
+}
+
+// foldTags moves tags into metadata for providers without native tagging. The returned metadata is a copy.
+func foldTags(provider v1.Provider, o v1.TargetOptions) v1.TargetOptions {
+	if len(o.Tags) == 0 || tagRules[provider].native {
+		return o
+	}
+
+	q := make(url.Values, len(o.Tags))
+	for k, v := range o.Tags {
+		q.Set(k, v)
+	}
+
+	md := make(map[string]string, len(o.Metadata)+1)
+	for k, v := range o.Metadata {
+		md[k] = v
+	}
+	md[tagsMetadataKey] = q.Encode()
+
+	o.Metadata = md
+	o.Tags = nil
+	return o
+}
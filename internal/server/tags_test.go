@@ -0,0 +1,84 @@
+package server
+
+import (
+	"strings"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tags", func() {
+	type tagTestCase struct {
+		provider        v1.Provider
+		tags            map[string]string
+		expectErrSubstr string
+	}
+
+	DescribeTable("validateTags",
+		func(tc tagTestCase) {
+			err := validateTags(tc.provider, tc.tags)
+			if tc.expectErrSubstr == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(tc.expectErrSubstr))
+			}
+		},
+
+		Entry("aws: lifecycle tag", tagTestCase{
+			provider: v1.ProviderAWS,
+			tags:     map[string]string{"retention": "90d", "owner": "data@example.com"},
+		}),
+		Entry("aws: too many tags", tagTestCase{
+			provider: v1.ProviderAWS,
+			tags: map[string]string{
+				"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6",
+				"g": "7", "h": "8", "i": "9", "j": "10", "k": "11",
+			},
+			expectErrSubstr: "too many tags for aws (max 10)",
+		}),
+		Entry("aws: key too long", tagTestCase{
+			provider:        v1.ProviderAWS,
+			tags:            map[string]string{strings.Repeat("k", 129): "v"},
+			expectErrSubstr: "must be 1-128 characters",
+		}),
+		Entry("aws: value too long", tagTestCase{
+			provider:        v1.ProviderAWS,
+			tags:            map[string]string{"k": strings.Repeat("v", 257)},
+			expectErrSubstr: "tag value too long: k (max 256 characters)",
+		}),
+		Entry("aws: disallowed character", tagTestCase{
+			provider:        v1.ProviderAWS,
+			tags:            map[string]string{"k": "a&b"},
+			expectErrSubstr: "contains characters not allowed by aws",
+		}),
+		Entry("azure: @ is not allowed", tagTestCase{
+			provider:        v1.ProviderAzure,
+			tags:            map[string]string{"owner": "data@example.com"},
+			expectErrSubstr: "contains characters not allowed by azure",
+		}),
+	)
+
+	It("folds tags into metadata for gcp only", func() {
+		o := v1.TargetOptions{
+			Metadata: map[string]string{"owner": "team-a"},
+			Tags:     map[string]string{"retention": "90d", "team": "data"},
+		}
+
+		Expect(foldTags(v1.ProviderAWS, o)).To(Equal(o))
+
+		folded := foldTags(v1.ProviderGCP, o)
+		Expect(folded.Tags).To(BeNil())
+		Expect(folded.Metadata).To(Equal(map[string]string{
+			"owner":      "team-a",
+			"bsync_tags": "retention=90d&team=data",
+		}))
+		Expect(o.Metadata).To(HaveLen(1))
+	})
+
+	It("reserves the tags metadata key", func() {
+		_, err := normalizeMetadata(map[string]string{"BSYNC_TAGS": "x"}, []v1.Provider{v1.ProviderGCP})
+		Expect(err).To(MatchError(ContainSubstring("metadata key bsync_tags is reserved for tags")))
+	})
+})
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/notify"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"github.com/jordanharrington/bsync/internal/verify"
+	"github.com/samber/lo"
+)
+
+// createUploadSession records a pending session for the targets signed in urls.
+func (h *handler) createUploadSession(ctx context.Context, id string, in v1.PutObjectRequest, urls []v1.PresignedUrl) error {
+	now := time.Now().UTC()
+	return h.uploads.Create(ctx, v1.UploadSession{
+		ID:            id,
+		State:         v1.UploadPending,
+		Targets:       jobTargets(in),
+		ContentType:   in.ContentType,
+		ContentLength: in.ContentLength,
+		ContentMD5:    in.ContentMD5,
+		WriteQuorum:   in.WriteQuorum,
+		CreatedAt:     now,
+		ExpiresAt:     latestExpiry(now, urls),
+	})
+}
+
+// handleCompleteUpload handles http.MethodPost to /v1/uploads/{id}/complete
+func (h *handler) handleCompleteUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+
+	var in v1.CompleteUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.uploads.Get(ctx, id)
+	if err == nil && session.State != v1.UploadPending {
+		err = fmt.Errorf("%w: %s", errUploadClosed, session.State)
+	}
+	if err != nil {
+		writeUploadError(w, id, err)
+		return
+	}
+	if err := validateCompleteRequest(session, in); err != nil {
+		http.Error(w, fmt.Sprintf("failed to validate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.completeUpload(ctx, session, in.Results)
+	if err != nil {
+		writeUploadError(w, id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v1.CompleteUploadResponse{
+		Upload: updated,
+	})
+}
+
+// completeUpload checks the primary targets of session against the reported results and closes it as
+// committed or failed. The session commits once its write quorum of primaries is confirmed; async targets
+// are left to the verification worker.
+func (h *handler) completeUpload(ctx context.Context, session v1.UploadSession, results []v1.UploadResult) (v1.UploadSession, error) {
+	primaries := primaryTargets(session.Targets)
+	quorum := session.WriteQuorum
+	if quorum == 0 {
+		quorum = len(primaries)
+	}
+
+	// Check with the provider before taking the session for update, so the HEADs are not run while the
+	// store holds a lock. Only the primaries the client says it wrote are checked.
+	session.Targets = withReportedVersions(session.Targets, results)
+	written := writtenTargets(session.Targets, results)
+	var (
+		failure string
+		status  v1.ReplicationStatus
+	)
+	if len(written) < quorum {
+		failure = reportedFailure(results)
+	} else {
+		job := uploadJob(session)
+		job.Targets = written
+		status = h.replicas.Check(ctx, job)
+		if confirmedReplicas(status) < quorum {
+			failure = replicationFailure(status)
+		}
+	}
+	if failure != "" && quorum < len(primaries) {
+		failure = fmt.Sprintf("write quorum %d of %d not met: %s", quorum, len(primaries), failure)
+	}
+
+	updated, err := h.uploads.Update(ctx, session.ID, func(s *v1.UploadSession) error {
+		if s.State != v1.UploadPending {
+			return fmt.Errorf("%w: %s", errUploadClosed, s.State)
+		}
+
+		now := time.Now().UTC()
+		s.Targets = session.Targets
+		s.CompletedAt = &now
+		if status.Replicas != nil {
+			checked := status.CheckedAt
+			s.Replication = status.State
+			s.Replicas = status.Replicas
+			s.VerifiedAt = &checked
+		}
+		s.State = v1.UploadCommitted
+		if failure != "" {
+			s.State = v1.UploadFailed
+			s.Error = failure
+		}
+		return nil
+	})
+	if err != nil {
+		return updated, err
+	}
+	if h.events != nil {
+		h.events.Notify(ctx, notify.UploadEvent(updated))
+	}
+	return updated, nil
+}
+
+// errUploadClosed is returned when a client completes an upload that is already committed or failed.
+var errUploadClosed = errors.New("upload is no longer pending")
+
+// validateCompleteRequest requires exactly one result per primary target of s.
+func validateCompleteRequest(s v1.UploadSession, in v1.CompleteUploadRequest) error {
+	targets := make(map[string]bool, len(s.Targets))
+	async := make(map[string]bool)
+	for _, t := range s.Targets {
+		if t.Role == v1.RoleAsync {
+			async[uploads.TargetKey(t)] = true
+			continue
+		}
+		targets[uploads.TargetKey(t)] = true
+	}
+
+	reported := make(map[string]bool, len(in.Results))
+	for _, res := range in.Results {
+		k := uploads.TargetKey(res.TargetRef)
+		if async[k] {
+			return fmt.Errorf("target %s is async and is written by the gateway", k)
+		}
+		if !targets[k] {
+			return fmt.Errorf("target %s is not part of upload %s", k, s.ID)
+		}
+		if reported[k] {
+			return fmt.Errorf("duplicate result for target %s", k)
+		}
+		if res.VersionID != "" {
+			if err := validateVersionID(res.TargetRef.Provider, res.VersionID); err != nil {
+				return err
+			}
+		}
+		reported[k] = true
+	}
+	for k := range targets {
+		if !reported[k] {
+			return fmt.Errorf("missing result for target %s", k)
+		}
+	}
+	return nil
+}
+
+// withReportedVersions pins each target to the version the client says its write created, so the check
+// looks at that write rather than whatever is current.
+func withReportedVersions(targets []v1.TargetRef, results []v1.UploadResult) []v1.TargetRef {
+	versions := make(map[string]string, len(results))
+	for _, res := range results {
+		versions[uploads.TargetKey(res.TargetRef)] = res.VersionID
+	}
+
+	out := make([]v1.TargetRef, len(targets))
+	for i, t := range targets {
+		t.VersionID = versions[uploads.TargetKey(t)]
+		out[i] = t
+	}
+	return out
+}
+
+// writtenTargets returns the primary targets whose result reports a successful write.
+func writtenTargets(targets []v1.TargetRef, results []v1.UploadResult) []v1.TargetRef {
+	ok := make(map[string]bool, len(results))
+	for _, res := range results {
+		ok[uploads.TargetKey(res.TargetRef)] = res.Error == "" && res.StatusCode/100 == 2
+	}
+	return lo.Filter(primaryTargets(targets), func(t v1.TargetRef, _ int) bool { return ok[uploads.TargetKey(t)] })
+}
+
+// confirmedReplicas counts the replicas of st that exist and match.
+func confirmedReplicas(st v1.ReplicationStatus) int {
+	return lo.CountBy(st.Replicas, func(r v1.ReplicaStatus) bool { return r.Exists && r.Mismatch == "" && r.Error == "" })
+}
+
+// reportedFailure describes the first target the client could not write, or returns "".
+func reportedFailure(results []v1.UploadResult) string {
+	for _, res := range results {
+		if res.Error != "" || res.StatusCode/100 != 2 {
+			return fmt.Sprintf("upload to %s failed: status %d %s", uploads.TargetKey(res.TargetRef), res.StatusCode, res.Error)
+		}
+	}
+	return ""
+}
+
+// replicationFailure describes why st does not confirm every replica, or returns "".
+func replicationFailure(st v1.ReplicationStatus) string {
+	if st.State == v1.ReplicationComplete {
+		return ""
+	}
+
+	var reasons []string
+	for _, r := range st.Replicas {
+		switch {
+		case r.Mismatch != "":
+			reasons = append(reasons, fmt.Sprintf("%s: %s", uploads.TargetKey(r.TargetRef), r.Mismatch))
+		case r.Error != "":
+			reasons = append(reasons, fmt.Sprintf("%s: %s", uploads.TargetKey(r.TargetRef), r.Error))
+		case !r.Exists:
+			reasons = append(reasons, fmt.Sprintf("%s: not found", uploads.TargetKey(r.TargetRef)))
+		}
+	}
+	return fmt.Sprintf("replication %s: %s", st.State, strings.Join(reasons, "; "))
+}
+
+func uploadJob(s v1.UploadSession) verify.Job {
+	return verify.Job{
+		ID:            s.ID,
+		Targets:       s.Targets,
+		ContentType:   s.ContentType,
+		ContentLength: s.ContentLength,
+		ContentMD5:    s.ContentMD5,
+		WriteQuorum:   s.WriteQuorum,
+	}
+}
+
+func writeUploadError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, uploads.ErrNotFound):
+		http.Error(w, fmt.Sprintf("upload not found: %s", id), http.StatusNotFound)
+	case errors.Is(err, errUploadClosed), errors.Is(err, uploads.ErrConflict):
+		http.Error(w, fmt.Sprintf("failed to complete upload %s: %v", id, err), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("failed to complete upload %s: %v", id, err), http.StatusServiceUnavailable)
+	}
+}
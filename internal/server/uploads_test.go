@@ -0,0 +1,259 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/mux"
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	"github.com/jordanharrington/bsync/internal/uploads"
+	"github.com/jordanharrington/bsync/internal/verify"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingNotifier keeps every event it is sent.
+type recordingNotifier struct {
+	events []v1.Event
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, ev v1.Event) {
+	n.events = append(n.events, ev)
+}
+
+var _ = Describe("Uploads", func() {
+	var (
+		aws    *mockPresigner
+		hnd    *handler
+		store  *uploads.MemoryStore
+		events *recordingNotifier
+		srv    *httptest.Server
+		stored map[string]bool
+	)
+
+	target := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b1", Key: "k1"}
+
+	BeforeEach(func() {
+		aws = &mockPresigner{}
+		store = uploads.NewMemoryStore()
+		stored = map[string]bool{}
+
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !stored[r.URL.Path] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", "4")
+			w.WriteHeader(http.StatusOK)
+		}))
+		DeferCleanup(srv.Close)
+
+		hnd = &handler{
+			signers: presign.Registry{
+				v1.ProviderAWS: aws,
+			},
+			keys: defaultKeyPolicy(),
+		}
+		WithUploadSessions(store, verify.WithReplicationHTTPClient(srv.Client()))(hnd)
+		events = &recordingNotifier{}
+		WithNotifier(events)(hnd)
+
+		aws.
+			On("PresignPut", mock.Anything, "bsync-b1", "k1", mock.Anything).
+			Return(&v1.PresignedUrl{TargetRef: target, URL: "https://signed/put"}, nil).
+			Maybe()
+		aws.
+			On("PresignHead", mock.Anything, "bsync-b1", "k1", mock.Anything).
+			Return(&v1.PresignedUrl{TargetRef: target, URL: srv.URL + "/k1"}, nil).
+			Maybe()
+	})
+
+	start := func() string {
+		bs, _ := json.Marshal(v1.PutObjectRequest{
+			ContentType:        "text/plain",
+			ContentLength:      4,
+			ExpiresMillis:      (2 * time.Minute).Milliseconds(),
+			ReplicationTargets: []v1.TargetRef{target},
+		})
+		rr := httptest.NewRecorder()
+		hnd.handlePutObject(rr, httptest.NewRequest(http.MethodPost, "/v1/presign/put", bytes.NewReader(bs)))
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+
+		var resp v1.PutObjectResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.UploadID).NotTo(BeEmpty())
+		Expect(resp.VerificationID).To(BeEmpty())
+		return resp.UploadID
+	}
+
+	complete := func(id string, in v1.CompleteUploadRequest) *httptest.ResponseRecorder {
+		bs, _ := json.Marshal(in)
+		req := httptest.NewRequest(http.MethodPost, "/v1/uploads/"+id+"/complete", bytes.NewReader(bs))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		hnd.handleCompleteUpload(rr, req)
+		return rr
+	}
+
+	succeeded := v1.CompleteUploadRequest{Results: []v1.UploadResult{{TargetRef: target, StatusCode: http.StatusOK}}}
+
+	It("records a pending session for every presigned PUT", func() {
+		id := start()
+
+		s, err := store.Get(context.Background(), id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.State).To(Equal(v1.UploadPending))
+		Expect(s.Targets).To(ConsistOf(target))
+		Expect(s.ContentLength).To(Equal(int64(4)))
+	})
+
+	It("commits the session once every target is confirmed", func() {
+		id := start()
+		stored["/k1"] = true
+
+		rr := complete(id, succeeded)
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+
+		var resp v1.CompleteUploadResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Upload.State).To(Equal(v1.UploadCommitted))
+		Expect(resp.Upload.CompletedAt).NotTo(BeNil())
+		Expect(resp.Upload.Replicas).To(ConsistOf(HaveField("Exists", true)))
+
+		rr = complete(id, succeeded)
+		Expect(rr.Code).To(Equal(http.StatusConflict))
+		Expect(events.events).To(HaveLen(1))
+		Expect(events.events[0].Type).To(Equal(v1.EventUploadCommitted))
+		Expect(events.events[0].UploadID).To(Equal(id))
+		Expect(events.events[0].Targets).To(ConsistOf(target))
+		Expect(rr.Body.String()).To(ContainSubstring("upload is no longer pending: committed"))
+	})
+
+	It("fails the session when a reported write is not found", func() {
+		id := start()
+
+		rr := complete(id, succeeded)
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+
+		var resp v1.CompleteUploadResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Upload.State).To(Equal(v1.UploadFailed))
+		Expect(resp.Upload.Error).To(Equal("replication missing: aws:bsync-b1/k1: not found"))
+		Expect(events.events).To(ConsistOf(HaveField("Type", v1.EventUploadFailed)))
+	})
+
+	It("fails the session without checking when the client reports an error", func() {
+		id := start()
+
+		rr := complete(id, v1.CompleteUploadRequest{Results: []v1.UploadResult{
+			{TargetRef: target, StatusCode: http.StatusForbidden, Error: "AccessDenied"},
+		}})
+		Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+
+		var resp v1.CompleteUploadResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Upload.State).To(Equal(v1.UploadFailed))
+		Expect(resp.Upload.Error).To(ContainSubstring("status 403 AccessDenied"))
+		aws.AssertNumberOfCalls(GinkgoT(), "PresignHead", 0)
+	})
+
+	It("requires a result for every target", func() {
+		id := start()
+
+		rr := complete(id, v1.CompleteUploadRequest{})
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		Expect(rr.Body.String()).To(ContainSubstring("missing result for target aws:bsync-b1/k1"))
+	})
+
+	Context("with primary and async targets", func() {
+		second := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b2", Key: "k1"}
+		async := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bsync-b3", Key: "k1", Role: v1.RoleAsync}
+
+		BeforeEach(func() {
+			aws.
+				On("PresignHead", mock.Anything, "bsync-b2", "k1", mock.Anything).
+				Return(&v1.PresignedUrl{TargetRef: second, URL: srv.URL + "/b2"}, nil).
+				Maybe()
+		})
+
+		open := func(quorum int) string {
+			Expect(store.Create(context.Background(), v1.UploadSession{
+				ID:            "u1",
+				State:         v1.UploadPending,
+				Targets:       []v1.TargetRef{target, second, async},
+				ContentLength: 4,
+				WriteQuorum:   quorum,
+			})).To(Succeed())
+			return "u1"
+		}
+
+		decode := func(rr *httptest.ResponseRecorder) v1.UploadSession {
+			Expect(rr.Code).To(Equal(http.StatusOK), "body: %s", rr.Body.String())
+			var resp v1.CompleteUploadResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+			return resp.Upload
+		}
+
+		It("commits once the write quorum of primaries is confirmed", func() {
+			id := open(1)
+			stored["/k1"] = true
+
+			s := decode(complete(id, v1.CompleteUploadRequest{Results: []v1.UploadResult{
+				{TargetRef: target, StatusCode: http.StatusOK},
+				{TargetRef: second, StatusCode: http.StatusServiceUnavailable, Error: "SlowDown"},
+			}}))
+
+			Expect(s.State).To(Equal(v1.UploadCommitted))
+			Expect(s.Replicas).To(ConsistOf(HaveField("TargetRef.Bucket", "bsync-b1")))
+			aws.AssertNotCalled(GinkgoT(), "PresignHead", mock.Anything, "bsync-b3", mock.Anything, mock.Anything)
+		})
+
+		It("fails when fewer primaries than the quorum are confirmed", func() {
+			id := open(1)
+
+			s := decode(complete(id, v1.CompleteUploadRequest{Results: []v1.UploadResult{
+				{TargetRef: target, StatusCode: http.StatusOK},
+				{TargetRef: second, StatusCode: http.StatusServiceUnavailable, Error: "SlowDown"},
+			}}))
+
+			Expect(s.State).To(Equal(v1.UploadFailed))
+			Expect(s.Error).To(Equal("write quorum 1 of 2 not met: replication missing: aws:bsync-b1/k1: not found"))
+		})
+
+		It("requires every primary without a quorum", func() {
+			id := open(0)
+			stored["/k1"] = true
+
+			s := decode(complete(id, v1.CompleteUploadRequest{Results: []v1.UploadResult{
+				{TargetRef: target, StatusCode: http.StatusOK},
+				{TargetRef: second, StatusCode: http.StatusServiceUnavailable, Error: "SlowDown"},
+			}}))
+
+			Expect(s.State).To(Equal(v1.UploadFailed))
+			Expect(s.Error).To(Equal("upload to aws:bsync-b2/k1 failed: status 503 SlowDown"))
+		})
+
+		It("rejects results for async targets", func() {
+			id := open(0)
+
+			rr := complete(id, v1.CompleteUploadRequest{Results: []v1.UploadResult{
+				{TargetRef: target, StatusCode: http.StatusOK},
+				{TargetRef: second, StatusCode: http.StatusOK},
+				{TargetRef: async, StatusCode: http.StatusOK},
+			}})
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			Expect(rr.Body.String()).To(ContainSubstring("target aws:bsync-b3/k1 is async and is written by the gateway"))
+		})
+	})
+
+	It("returns 404 for unknown uploads", func() {
+		rr := complete("nope", succeeded)
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+})
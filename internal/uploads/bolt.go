@@ -0,0 +1,153 @@
+package uploads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltTimeout is how long an operation waits for another process to release the file.
+const boltTimeout = 5 * time.Second
+
+var (
+	boltBucket = []byte("uploads")
+	// boltTargets maps a TargetKey to the ID of the newest session that writes it.
+	boltTargets = []byte("targets")
+)
+
+// BoltStore is a Store in a local BoltDB file, for single-instance deployments that must survive restarts.
+// BoltDB locks the file for as long as it is open, so the store opens it for each operation instead of
+// holding it. That lets the gateway and bsync-verifier share one file, each waiting up to boltTimeout for
+// the other to finish.
+type BoltStore struct {
+	path string
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	b := &BoltStore{path: path}
+	err := b.update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltBucket, boltTargets} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload store: %v", err)
+	}
+	return b, nil
+}
+
+// Close is a no-op: the file is only open during an operation.
+func (b *BoltStore) Close() error {
+	return nil
+}
+
+func (b *BoltStore) view(fn func(*bolt.Tx) error) error {
+	db, err := b.open(true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	return db.View(fn)
+}
+
+func (b *BoltStore) update(fn func(*bolt.Tx) error) error {
+	db, err := b.open(false)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	return db.Update(fn)
+}
+
+func (b *BoltStore) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(b.path, 0o600, &bolt.Options{Timeout: boltTimeout, ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", b.path, err)
+	}
+	return db, nil
+}
+
+func (b *BoltStore) Create(_ context.Context, s v1.UploadSession) error {
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload: %v", err)
+	}
+
+	return b.update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(boltBucket)
+		if bkt.Get([]byte(s.ID)) != nil {
+			return ErrExists
+		}
+		if err := bkt.Put([]byte(s.ID), bs); err != nil {
+			return err
+		}
+
+		idx := tx.Bucket(boltTargets)
+		for _, t := range s.Targets {
+			if err := idx.Put([]byte(TargetKey(t)), []byte(s.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) Get(_ context.Context, id string) (v1.UploadSession, error) {
+	var s v1.UploadSession
+	err := b.view(func(tx *bolt.Tx) error {
+		return decodeBolt(tx, id, &s)
+	})
+	return s, err
+}
+
+func (b *BoltStore) Update(_ context.Context, id string, fn func(*v1.UploadSession) error) (v1.UploadSession, error) {
+	var s v1.UploadSession
+	err := b.update(func(tx *bolt.Tx) error {
+		if err := decodeBolt(tx, id, &s); err != nil {
+			return err
+		}
+		if err := fn(&s); err != nil {
+			return err
+		}
+
+		bs, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("failed to encode upload: %v", err)
+		}
+		return tx.Bucket(boltBucket).Put([]byte(id), bs)
+	})
+	if err != nil {
+		return v1.UploadSession{}, err
+	}
+	return s, nil
+}
+
+func (b *BoltStore) FindByTarget(_ context.Context, t v1.TargetRef) (v1.UploadSession, error) {
+	var s v1.UploadSession
+	err := b.view(func(tx *bolt.Tx) error {
+		id := tx.Bucket(boltTargets).Get([]byte(TargetKey(t)))
+		if id == nil {
+			return ErrNotFound
+		}
+		return decodeBolt(tx, string(id), &s)
+	})
+	return s, err
+}
+
+func decodeBolt(tx *bolt.Tx, id string, s *v1.UploadSession) error {
+	bs := tx.Bucket(boltBucket).Get([]byte(id))
+	if bs == nil {
+		return ErrNotFound
+	}
+	if err := json.Unmarshal(bs, s); err != nil {
+		return fmt.Errorf("failed to decode upload %s: %v", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package uploads
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/samber/lo"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+type dynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// dynamoTargetPrefix marks index items, which share the "id" keyspace with sessions. Session IDs never
+// take this form, so a lookup by upload ID cannot return an index item.
+const dynamoTargetPrefix = "target#"
+
+// dynamoMaxTransactItems is the most items DynamoDB accepts in one TransactWriteItems call.
+const dynamoMaxTransactItems = 100
+
+// DynamoStore is a Store in a DynamoDB table with a string partition key named "id". Each item holds the
+// session as JSON in "session" and a "rev" counter used for optimistic concurrency. Items with IDs of
+// the form "target#<TargetKey>" index the newest session that writes each target; they are written in
+// the same transaction as the session.
+type DynamoStore struct {
+	client dynamoAPI
+	table  string
+}
+
+func NewDynamoStore(ctx context.Context, table string, optFns ...func(*dynamodb.Options)) (*DynamoStore, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &DynamoStore{client: dynamodb.NewFromConfig(cfg, optFns...), table: table}, nil
+}
+
+func (d *DynamoStore) Create(ctx context.Context, s v1.UploadSession) error {
+	if strings.HasPrefix(s.ID, dynamoTargetPrefix) {
+		return fmt.Errorf("invalid upload id %s", s.ID)
+	}
+	item, err := dynamoItem(s, 1)
+	if err != nil {
+		return err
+	}
+
+	items := []types.TransactWriteItem{{
+		Put: &types.Put{
+			TableName:           &d.table,
+			Item:                item,
+			ConditionExpression: lo.ToPtr("attribute_not_exists(id)"),
+		},
+	}}
+	indexed := map[string]bool{}
+	for _, t := range s.Targets {
+		id := dynamoTargetID(t)
+		if indexed[id] {
+			continue
+		}
+		indexed[id] = true
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: &d.table,
+				Item: map[string]types.AttributeValue{
+					"id":        &types.AttributeValueMemberS{Value: id},
+					"upload_id": &types.AttributeValueMemberS{Value: s.ID},
+				},
+			},
+		})
+	}
+
+	if len(items) > dynamoMaxTransactItems {
+		return fmt.Errorf("failed to create upload %s: %d targets exceed the %d a transaction can index", s.ID, len(indexed), dynamoMaxTransactItems-1)
+	}
+
+	_, err = d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	var tce *types.TransactionCanceledException
+	if errors.As(err, &tce) && len(tce.CancellationReasons) > 0 &&
+		lo.FromPtr(tce.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+		return ErrExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create upload %s: %v", s.ID, err)
+	}
+	return nil
+}
+
+func (d *DynamoStore) Get(ctx context.Context, id string) (v1.UploadSession, error) {
+	s, _, err := d.get(ctx, id)
+	return s, err
+}
+
+// Update is a conditional put on the revision read, so a concurrent writer makes it fail with ErrConflict.
+func (d *DynamoStore) Update(ctx context.Context, id string, fn func(*v1.UploadSession) error) (v1.UploadSession, error) {
+	s, rev, err := d.get(ctx, id)
+	if err != nil {
+		return v1.UploadSession{}, err
+	}
+	if err := fn(&s); err != nil {
+		return v1.UploadSession{}, err
+	}
+
+	item, err := dynamoItem(s, rev+1)
+	if err != nil {
+		return v1.UploadSession{}, err
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           &d.table,
+		Item:                item,
+		ConditionExpression: lo.ToPtr("rev = :rev"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":rev": &types.AttributeValueMemberN{Value: strconv.FormatInt(rev, 10)},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return v1.UploadSession{}, ErrConflict
+	}
+	if err != nil {
+		return v1.UploadSession{}, fmt.Errorf("failed to update upload %s: %v", id, err)
+	}
+	return s, nil
+}
+
+func (d *DynamoStore) FindByTarget(ctx context.Context, t v1.TargetRef) (v1.UploadSession, error) {
+	out, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &d.table,
+		Key:            map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: dynamoTargetID(t)}},
+		ConsistentRead: lo.ToPtr(true),
+	})
+	if err != nil {
+		return v1.UploadSession{}, fmt.Errorf("failed to read index for %s: %v", TargetKey(t), err)
+	}
+	id, ok := out.Item["upload_id"].(*types.AttributeValueMemberS)
+	if !ok {
+		return v1.UploadSession{}, ErrNotFound
+	}
+	return d.Get(ctx, id.Value)
+}
+
+func dynamoTargetID(t v1.TargetRef) string {
+	return dynamoTargetPrefix + TargetKey(t)
+}
+
+func (d *DynamoStore) get(ctx context.Context, id string) (v1.UploadSession, int64, error) {
+	if strings.HasPrefix(id, dynamoTargetPrefix) {
+		return v1.UploadSession{}, 0, ErrNotFound
+	}
+	out, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &d.table,
+		Key:            map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+		ConsistentRead: lo.ToPtr(true),
+	})
+	if err != nil {
+		return v1.UploadSession{}, 0, fmt.Errorf("failed to read upload %s: %v", id, err)
+	}
+	if out.Item == nil {
+		return v1.UploadSession{}, 0, ErrNotFound
+	}
+
+	body, ok := out.Item["session"].(*types.AttributeValueMemberS)
+	if !ok {
+		return v1.UploadSession{}, 0, fmt.Errorf("failed to decode upload %s: missing session attribute", id)
+	}
+	rev, ok := out.Item["rev"].(*types.AttributeValueMemberN)
+	if !ok {
+		return v1.UploadSession{}, 0, fmt.Errorf("failed to decode upload %s: missing rev attribute", id)
+	}
+	n, err := strconv.ParseInt(rev.Value, 10, 64)
+	if err != nil {
+		return v1.UploadSession{}, 0, fmt.Errorf("failed to decode upload %s: %v", id, err)
+	}
+
+	var s v1.UploadSession
+	if err := json.Unmarshal([]byte(body.Value), &s); err != nil {
+		return v1.UploadSession{}, 0, fmt.Errorf("failed to decode upload %s: %v", id, err)
+	}
+	return s, n, nil
+}
+
+func dynamoItem(s v1.UploadSession, rev int64) (map[string]types.AttributeValue, error) {
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode upload: %v", err)
+	}
+	return map[string]types.AttributeValue{
+		"id":      &types.AttributeValueMemberS{Value: s.ID},
+		"session": &types.AttributeValueMemberS{Value: string(bs)},
+		"rev":     &types.AttributeValueMemberN{Value: strconv.FormatInt(rev, 10)},
+	}, nil
+}
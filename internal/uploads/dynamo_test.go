@@ -0,0 +1,177 @@
+package uploads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockDynamoAPI struct {
+	mock.Mock
+}
+
+func (m *mockDynamoAPI) GetItem(ctx context.Context, in *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
+}
+
+func (m *mockDynamoAPI) PutItem(ctx context.Context, in *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+func (m *mockDynamoAPI) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*dynamodb.TransactWriteItemsOutput), args.Error(1)
+}
+
+var _ = Describe("DynamoStore", func() {
+	var (
+		ctx   context.Context
+		api   *mockDynamoAPI
+		store *DynamoStore
+		item  map[string]types.AttributeValue
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		api = &mockDynamoAPI{}
+		store = &DynamoStore{client: api, table: "uploads"}
+
+		bs, _ := json.Marshal(v1.UploadSession{ID: "u1", State: v1.UploadPending})
+		item = map[string]types.AttributeValue{
+			"id":      &types.AttributeValueMemberS{Value: "u1"},
+			"session": &types.AttributeValueMemberS{Value: string(bs)},
+			"rev":     &types.AttributeValueMemberN{Value: "3"},
+		}
+	})
+
+	It("creates only new sessions", func() {
+		api.
+			On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(in *dynamodb.TransactWriteItemsInput) bool {
+				return *in.TransactItems[0].Put.ConditionExpression == "attribute_not_exists(id)"
+			})).
+			Return(&dynamodb.TransactWriteItemsOutput{}, &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{{Code: lo.ToPtr("ConditionalCheckFailed")}, {Code: lo.ToPtr("None")}},
+			}).
+			Once()
+
+		s := v1.UploadSession{ID: "u1", Targets: []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "k"}}}
+		Expect(store.Create(ctx, s)).To(MatchError(ErrExists))
+		api.AssertExpectations(GinkgoT())
+	})
+
+	It("writes a session and its index items in one transaction", func() {
+		api.
+			On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(in *dynamodb.TransactWriteItemsInput) bool {
+				if len(in.TransactItems) != 2 {
+					return false
+				}
+				idx := in.TransactItems[1].Put.Item
+				return idx["id"].(*types.AttributeValueMemberS).Value == "target#aws:bucket/k" &&
+					idx["upload_id"].(*types.AttributeValueMemberS).Value == "u1"
+			})).
+			Return(&dynamodb.TransactWriteItemsOutput{}, nil).
+			Once()
+
+		t := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "k"}
+		Expect(store.Create(ctx, v1.UploadSession{ID: "u1", Targets: []v1.TargetRef{t, t}})).To(Succeed())
+		api.AssertExpectations(GinkgoT())
+	})
+
+	It("refuses sessions with more targets than a transaction can index", func() {
+		targets := make([]v1.TargetRef, 100)
+		for i := range targets {
+			targets[i] = v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: fmt.Sprintf("k%d", i)}
+		}
+		Expect(store.Create(ctx, v1.UploadSession{ID: "u1", Targets: targets})).
+			To(MatchError("failed to create upload u1: 100 targets exceed the 99 a transaction can index"))
+		api.AssertNotCalled(GinkgoT(), "TransactWriteItems", mock.Anything, mock.Anything)
+	})
+
+	It("never reads an index item as a session", func() {
+		_, err := store.Get(ctx, "target#aws:bucket/k")
+		Expect(err).To(MatchError(ErrNotFound))
+		Expect(store.Create(ctx, v1.UploadSession{ID: "target#aws:bucket/k"})).To(MatchError(ContainSubstring("invalid upload id")))
+		api.AssertNotCalled(GinkgoT(), "GetItem", mock.Anything, mock.Anything)
+		api.AssertNotCalled(GinkgoT(), "TransactWriteItems", mock.Anything, mock.Anything)
+	})
+
+	It("updates conditionally on the revision it read", func() {
+		api.
+			On("GetItem", mock.Anything, mock.Anything).
+			Return(&dynamodb.GetItemOutput{Item: item}, nil)
+		api.
+			On("PutItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+				rev := in.ExpressionAttributeValues[":rev"].(*types.AttributeValueMemberN).Value
+				next := in.Item["rev"].(*types.AttributeValueMemberN).Value
+				return *in.ConditionExpression == "rev = :rev" && rev == "3" && next == "4"
+			})).
+			Return(&dynamodb.PutItemOutput{}, nil).
+			Once()
+
+		s, err := store.Update(ctx, "u1", func(s *v1.UploadSession) error {
+			s.State = v1.UploadCommitted
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.State).To(Equal(v1.UploadCommitted))
+		api.AssertExpectations(GinkgoT())
+	})
+
+	It("reports a concurrent update as a conflict", func() {
+		api.
+			On("GetItem", mock.Anything, mock.Anything).
+			Return(&dynamodb.GetItemOutput{Item: item}, nil)
+		api.
+			On("PutItem", mock.Anything, mock.Anything).
+			Return(&dynamodb.PutItemOutput{}, &types.ConditionalCheckFailedException{}).
+			Once()
+
+		_, err := store.Update(ctx, "u1", func(*v1.UploadSession) error { return nil })
+		Expect(err).To(MatchError(ErrConflict))
+	})
+
+	It("reports unknown sessions as not found", func() {
+		api.
+			On("GetItem", mock.Anything, mock.Anything).
+			Return(&dynamodb.GetItemOutput{}, nil)
+
+		_, err := store.Get(ctx, "missing")
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("finds the newest session for a target through its index item", func() {
+		api.
+			On("GetItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+				return in.Key["id"].(*types.AttributeValueMemberS).Value == "target#aws:bucket/k"
+			})).
+			Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"id":        &types.AttributeValueMemberS{Value: "target#aws:bucket/k"},
+				"upload_id": &types.AttributeValueMemberS{Value: "u1"},
+			}}, nil).
+			Once()
+		api.
+			On("GetItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+				return in.Key["id"].(*types.AttributeValueMemberS).Value == "u1"
+			})).
+			Return(&dynamodb.GetItemOutput{Item: item}, nil).
+			Once()
+
+		s, err := store.FindByTarget(ctx, v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "k"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.ID).To(Equal("u1"))
+		api.AssertExpectations(GinkgoT())
+	})
+})
@@ -0,0 +1,120 @@
+package uploads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+var (
+	// ErrNotFound is returned for an upload ID the store does not know.
+	ErrNotFound = errors.New("upload not found")
+	// ErrExists is returned when creating an upload whose ID is already taken.
+	ErrExists = errors.New("upload already exists")
+	// ErrConflict is returned when an upload changed between the read and the write of an Update.
+	ErrConflict = errors.New("upload was modified concurrently")
+)
+
+// Store persists upload sessions. Implementations must make Update atomic for a single session.
+type Store interface {
+	Create(ctx context.Context, s v1.UploadSession) error
+	Get(ctx context.Context, id string) (v1.UploadSession, error)
+	// Update applies fn to the stored session and saves the result. Nothing is saved if fn returns an error.
+	Update(ctx context.Context, id string, fn func(*v1.UploadSession) error) (v1.UploadSession, error)
+	// FindByTarget returns the most recently created session that writes t, ignoring t.VersionID.
+	FindByTarget(ctx context.Context, t v1.TargetRef) (v1.UploadSession, error)
+}
+
+// TargetKey identifies a target across sessions as provider:bucket/key.
+func TargetKey(t v1.TargetRef) string {
+	return fmt.Sprintf("%s:%s/%s", t.Provider, t.Bucket, t.Key)
+}
+
+// recordAttempts is how many times RecordStatus tries an update that keeps conflicting with other writers.
+const recordAttempts = 3
+
+// RecordStatus stores a verification result on the session it was queued for. An update that conflicts
+// with a concurrent write, such as an ingested event, is read and applied again.
+func RecordStatus(ctx context.Context, store Store, st v1.ReplicationStatus) error {
+	for attempt := 1; ; attempt++ {
+		_, err := store.Update(ctx, st.VerificationID, func(s *v1.UploadSession) error {
+			checked := st.CheckedAt
+			s.Replication = st.State
+			s.Replicas = st.Replicas
+			s.VerifiedAt = &checked
+			return nil
+		})
+		if !errors.Is(err, ErrConflict) || attempt == recordAttempts {
+			return err
+		}
+	}
+}
+
+// MemoryStore is an in-process Store for tests and single-instance deployments.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]v1.UploadSession
+	// latest maps a TargetKey to the ID of the newest session that writes it.
+	latest map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]v1.UploadSession),
+		latest:   make(map[string]string),
+	}
+}
+
+func (m *MemoryStore) Create(_ context.Context, s v1.UploadSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[s.ID]; ok {
+		return ErrExists
+	}
+	m.sessions[s.ID] = s
+	for _, t := range s.Targets {
+		m.latest[TargetKey(t)] = s.ID
+	}
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, id string) (v1.UploadSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return v1.UploadSession{}, ErrNotFound
+	}
+	return s, nil
+}
+
+func (m *MemoryStore) Update(_ context.Context, id string, fn func(*v1.UploadSession) error) (v1.UploadSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return v1.UploadSession{}, ErrNotFound
+	}
+	if err := fn(&s); err != nil {
+		return v1.UploadSession{}, err
+	}
+	m.sessions[id] = s
+	return s, nil
+}
+
+func (m *MemoryStore) FindByTarget(_ context.Context, t v1.TargetRef) (v1.UploadSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.latest[TargetKey(t)]
+	if !ok {
+		return v1.UploadSession{}, ErrNotFound
+	}
+	return m.sessions[id], nil
+}
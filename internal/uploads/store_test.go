@@ -0,0 +1,150 @@
+package uploads
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestUploads(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Uploads")
+}
+
+// conflictingStore fails the first conflicts updates with ErrConflict.
+type conflictingStore struct {
+	Store
+	conflicts, updates int
+}
+
+func (s *conflictingStore) Update(ctx context.Context, id string, fn func(*v1.UploadSession) error) (v1.UploadSession, error) {
+	s.updates++
+	if s.updates <= s.conflicts {
+		return v1.UploadSession{}, ErrConflict
+	}
+	return s.Store.Update(ctx, id, fn)
+}
+
+var _ = Describe("Store", func() {
+	session := v1.UploadSession{
+		ID:        "u1",
+		State:     v1.UploadPending,
+		Targets:   []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "k"}},
+		CreatedAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	type storeFactory func() Store
+
+	DescribeTable("round-trips sessions and applies updates atomically",
+		func(newStore storeFactory) {
+			ctx := context.Background()
+			store := newStore()
+
+			Expect(store.Create(ctx, session)).To(Succeed())
+			Expect(store.Create(ctx, session)).To(MatchError(ErrExists))
+
+			got, err := store.Get(ctx, "u1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal(session))
+
+			_, err = store.Get(ctx, "missing")
+			Expect(err).To(MatchError(ErrNotFound))
+
+			updated, err := store.Update(ctx, "u1", func(s *v1.UploadSession) error {
+				s.State = v1.UploadCommitted
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated.State).To(Equal(v1.UploadCommitted))
+
+			boom := errors.New("boom")
+			_, err = store.Update(ctx, "u1", func(s *v1.UploadSession) error {
+				s.State = v1.UploadFailed
+				return boom
+			})
+			Expect(err).To(MatchError(boom))
+
+			got, err = store.Get(ctx, "u1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got.State).To(Equal(v1.UploadCommitted))
+
+			newer := session
+			newer.ID = "u2"
+			Expect(store.Create(ctx, newer)).To(Succeed())
+
+			found, err := store.FindByTarget(ctx, v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "k", VersionID: "v1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.ID).To(Equal("u2"))
+
+			_, err = store.FindByTarget(ctx, v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "bucket", Key: "k"})
+			Expect(err).To(MatchError(ErrNotFound))
+
+			checked := time.Date(2030, 1, 1, 0, 5, 0, 0, time.UTC)
+			Expect(RecordStatus(ctx, store, v1.ReplicationStatus{
+				VerificationID: "u2",
+				State:          v1.ReplicationPartial,
+				Replicas:       []v1.ReplicaStatus{{TargetRef: session.Targets[0], Exists: true}},
+				CheckedAt:      checked,
+			})).To(Succeed())
+
+			got, err = store.Get(ctx, "u2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got.Replication).To(Equal(v1.ReplicationPartial))
+			Expect(got.Replicas).To(HaveLen(1))
+			Expect(*got.VerifiedAt).To(BeTemporally("==", checked))
+		},
+
+		Entry("memory", storeFactory(func() Store { return NewMemoryStore() })),
+		Entry("bolt", storeFactory(func() Store {
+			s, err := NewBoltStore(filepath.Join(GinkgoT().TempDir(), "uploads.db"))
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(s.Close)
+			return s
+		})),
+	)
+
+	DescribeTable("RecordStatus retries conflicting updates a bounded number of times",
+		func(conflicts int, expectErr error, expectUpdates int) {
+			ctx := context.Background()
+			store := &conflictingStore{Store: NewMemoryStore(), conflicts: conflicts}
+			Expect(store.Create(ctx, session)).To(Succeed())
+
+			err := RecordStatus(ctx, store, v1.ReplicationStatus{VerificationID: "u1", State: v1.ReplicationComplete})
+			if expectErr == nil {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(expectErr))
+			}
+			Expect(store.updates).To(Equal(expectUpdates))
+		},
+		Entry("conflicts once", 1, nil, 2),
+		Entry("keeps conflicting", 5, ErrConflict, 3),
+	)
+
+	It("bolt: shares one file between stores", func() {
+		ctx := context.Background()
+		path := filepath.Join(GinkgoT().TempDir(), "uploads.db")
+
+		gateway, err := NewBoltStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		verifier, err := NewBoltStore(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(gateway.Create(ctx, session)).To(Succeed())
+		_, err = verifier.Update(ctx, "u1", func(s *v1.UploadSession) error {
+			s.State = v1.UploadCommitted
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		got, err := gateway.Get(ctx, "u1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.State).To(Equal(v1.UploadCommitted))
+	})
+})
@@ -0,0 +1,158 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+)
+
+// sniffLen is the number of leading bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// sniffCompatible lists, per declared content type, the sniffed media types that are accepted as a match.
+// http.DetectContentType cannot tell JSON from plain text, and anything it does not recognise is
+// reported as application/octet-stream. Declaring application/octet-stream accepts any content.
+var sniffCompatible = map[string][]string{
+	"application/json": {"text/plain", "application/json"},
+	"text/plain":       {"text/plain"},
+	"image/png":        {"image/png"},
+	"image/jpeg":       {"image/jpeg"},
+}
+
+// ContentTypeResult is the outcome of sniffing a single replica.
+type ContentTypeResult struct {
+	Target   v1.TargetRef
+	Declared string
+	Detected string
+	Match    bool
+	Err      error
+}
+
+// MismatchFunc is invoked for every replica whose sniffed type does not match the declared type.
+type MismatchFunc func(ctx context.Context, res ContentTypeResult)
+
+// ContentTypeVerifier reads the first bytes of each replica through a presigned ranged GET and
+// compares the sniffed media type to the one the client declared at presign time.
+type ContentTypeVerifier struct {
+	signers    presign.Registry
+	client     *http.Client
+	ttl        time.Duration
+	onMismatch MismatchFunc
+}
+
+// Option mutates a ContentTypeVerifier.
+type Option func(*ContentTypeVerifier)
+
+// WithHTTPClient sets the client used to fetch replicas.
+func WithHTTPClient(c *http.Client) Option {
+	return func(v *ContentTypeVerifier) { v.client = c }
+}
+
+// WithOnMismatch sets a hook used to quarantine or flag mismatched replicas.
+func WithOnMismatch(fn MismatchFunc) Option {
+	return func(v *ContentTypeVerifier) { v.onMismatch = fn }
+}
+
+func NewContentTypeVerifier(signers presign.Registry, opts ...Option) *ContentTypeVerifier {
+	v := &ContentTypeVerifier{
+		signers: signers,
+		client:  http.DefaultClient,
+		ttl:     time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify sniffs every target and returns one result per target, in order.
+func (v *ContentTypeVerifier) Verify(ctx context.Context, declared string, targets []v1.TargetRef) []ContentTypeResult {
+	results := make([]ContentTypeResult, 0, len(targets))
+	for _, t := range targets {
+		results = append(results, v.Check(ctx, declared, t))
+	}
+	return results
+}
+
+// Check sniffs a single target against declared and runs the mismatch hook if it does not match.
+func (v *ContentTypeVerifier) Check(ctx context.Context, declared string, t v1.TargetRef) ContentTypeResult {
+	res := ContentTypeResult{Target: t, Declared: declared}
+	res.Detected, res.Err = v.sniff(ctx, t)
+	if res.Err == nil {
+		res.Match = contentTypeMatches(declared, res.Detected)
+		if !res.Match && v.onMismatch != nil {
+			v.onMismatch(ctx, res)
+		}
+	}
+	return res
+}
+
+func (v *ContentTypeVerifier) sniff(ctx context.Context, t v1.TargetRef) (string, error) {
+	presigner, ok := v.signers[t.Provider]
+	if !ok {
+		return "", fmt.Errorf("provider not configured: %s", t.Provider)
+	}
+
+	opts := presign.NewGetOptions(
+		presign.WithGetTTL(v.ttl),
+		presign.WithRange(fmt.Sprintf("bytes=0-%d", sniffLen-1)),
+		presign.WithVersion(t.VersionID),
+	)
+	u, err := presigner.PresignGet(ctx, t.Bucket, t.Key, opts)
+	if err != nil {
+		return "", fmt.Errorf("presign failed for %s: %w", t.Provider, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, vals := range u.HeaderValues {
+		for _, val := range vals {
+			req.Header.Add(k, val)
+		}
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode/100 != 2 {
+		return "", fmt.Errorf("read failed: %s", res.Status)
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(res.Body, sniffLen))
+	if err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf), nil
+}
+
+// contentTypeMatches reports whether the sniffed type is acceptable for the declared type.
+func contentTypeMatches(declared, detected string) bool {
+	if strings.EqualFold(declared, "application/octet-stream") {
+		return true
+	}
+
+	dt, _, err := mime.ParseMediaType(detected)
+	if err != nil {
+		return false
+	}
+
+	for _, ok := range sniffCompatible[strings.ToLower(declared)] {
+		if dt == ok {
+			return true
+		}
+	}
+	return false
+}
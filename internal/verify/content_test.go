@@ -0,0 +1,167 @@
+package verify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestVerify(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Verify")
+}
+
+type mockPresigner struct {
+	mock.Mock
+}
+
+func (m *mockPresigner) PresignPut(ctx context.Context, bucket, key string, opts presign.PutOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
+func (m *mockPresigner) PresignGet(ctx context.Context, bucket, key string, opts presign.GetOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
+func (m *mockPresigner) PresignHead(ctx context.Context, bucket, key string, opts presign.HeadOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
+func (m *mockPresigner) PresignDelete(ctx context.Context, bucket, key string, opts presign.DeleteOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
+func (m *mockPresigner) PresignCopy(ctx context.Context, bucket, key string, opts presign.CopyOptions) (*v1.PresignedUrl, error) {
+	args := m.Called(ctx, bucket, key, opts)
+
+	return args.Get(0).(*v1.PresignedUrl), args.Error(1)
+}
+
+var (
+	pngBytes = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+	elfBytes = []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+)
+
+var _ = Describe("ContentTypeVerifier", func() {
+	var (
+		ctx    context.Context
+		aws    *mockPresigner
+		srv    *httptest.Server
+		ranges []string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		aws = &mockPresigner{}
+		ranges = nil
+
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ranges = append(ranges, r.Header.Get("Range"))
+			switch r.URL.Path {
+			case "/png":
+				_, _ = w.Write(pngBytes)
+			case "/elf":
+				_, _ = w.Write(elfBytes)
+			case "/json":
+				_, _ = w.Write([]byte(`{"key":"value"}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		DeferCleanup(srv.Close)
+	})
+
+	signTo := func(key, path string) {
+		aws.
+			On("PresignGet", mock.Anything, "bucket", key, mock.MatchedBy(func(o presign.GetOptions) bool {
+				return o.Range == "bytes=0-511"
+			})).
+			Return(&v1.PresignedUrl{
+				TargetRef: v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: key},
+				URL:       srv.URL + path,
+				HeaderValues: map[string][]string{
+					"Range": {"bytes=0-511"},
+				},
+			}, nil).
+			Once()
+	}
+
+	type verifyTestCase struct {
+		declared     string
+		path         string
+		expectMatch  bool
+		expectDetect string
+		expectErr    bool
+	}
+
+	DescribeTable("Verify",
+		func(tc verifyTestCase) {
+			signTo("k", tc.path)
+
+			var flagged []ContentTypeResult
+			v := NewContentTypeVerifier(
+				presign.Registry{v1.ProviderAWS: aws},
+				WithHTTPClient(srv.Client()),
+				WithOnMismatch(func(_ context.Context, res ContentTypeResult) {
+					flagged = append(flagged, res)
+				}),
+			)
+
+			results := v.Verify(ctx, tc.declared, []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "k"}})
+			Expect(results).To(HaveLen(1))
+			res := results[0]
+
+			if tc.expectErr {
+				Expect(res.Err).To(HaveOccurred())
+				Expect(flagged).To(BeEmpty())
+			} else {
+				Expect(res.Err).NotTo(HaveOccurred())
+				Expect(res.Detected).To(HavePrefix(tc.expectDetect))
+				Expect(res.Match).To(Equal(tc.expectMatch))
+				Expect(flagged).To(HaveLen(map[bool]int{true: 0, false: 1}[tc.expectMatch]))
+			}
+			Expect(ranges).To(ConsistOf("bytes=0-511"))
+
+			aws.AssertExpectations(GinkgoT())
+		},
+
+		Entry("png labelled image/png", verifyTestCase{
+			declared: "image/png", path: "/png", expectMatch: true, expectDetect: "image/png",
+		}),
+		Entry("executable labelled image/png is flagged", verifyTestCase{
+			declared: "image/png", path: "/elf", expectMatch: false, expectDetect: "application/octet-stream",
+		}),
+		Entry("json sniffs as text", verifyTestCase{
+			declared: "application/json", path: "/json", expectMatch: true, expectDetect: "text/plain",
+		}),
+		Entry("octet-stream accepts anything", verifyTestCase{
+			declared: "application/octet-stream", path: "/png", expectMatch: true, expectDetect: "image/png",
+		}),
+		Entry("missing replica is an error, not a mismatch", verifyTestCase{
+			declared: "image/png", path: "/missing", expectErr: true,
+		}),
+	)
+
+	It("reports unconfigured providers without fetching", func() {
+		v := NewContentTypeVerifier(presign.Registry{v1.ProviderAWS: aws})
+		results := v.Verify(ctx, "image/png", []v1.TargetRef{{Provider: v1.ProviderGCP, Bucket: "bucket", Key: "k"}})
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Err).To(MatchError(ContainSubstring("provider not configured: gcp")))
+		Expect(ranges).To(BeEmpty())
+	})
+})
@@ -0,0 +1,153 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileQueue is a Queue backed by a directory, one JSON file per job. Pending jobs live in pending/ and
+// received jobs in inflight/ until they are acked. Files that do not decode as a job are moved to dead/.
+// Any number of processes may enqueue, but it is meant for a single consumer process: the first Receive
+// moves jobs a previous consumer left in flight back to pending.
+type FileQueue struct {
+	pending  string
+	inflight string
+	dead     string
+	poll     time.Duration
+
+	recovered  sync.Once
+	recoverErr error
+}
+
+func NewFileQueue(dir string) (*FileQueue, error) {
+	q := &FileQueue{
+		pending:  filepath.Join(dir, "pending"),
+		inflight: filepath.Join(dir, "inflight"),
+		dead:     filepath.Join(dir, "dead"),
+		poll:     250 * time.Millisecond,
+	}
+
+	for _, d := range []string{q.pending, q.inflight, q.dead} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create queue directory: %v", err)
+		}
+	}
+	return q, nil
+}
+
+// requeueInflight moves every job in inflight/ back to pending/.
+func (q *FileQueue) requeueInflight() error {
+	stale, err := os.ReadDir(q.inflight)
+	if err != nil {
+		return fmt.Errorf("failed to read queue directory: %v", err)
+	}
+	for _, e := range stale {
+		if err := os.Rename(filepath.Join(q.inflight, e.Name()), filepath.Join(q.pending, e.Name())); err != nil {
+			return fmt.Errorf("failed to requeue %s: %v", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Enqueue writes the job to a temporary file and renames it into pending/, so a consumer never reads a
+// partially written job. File names sort in enqueue order.
+func (q *FileQueue) Enqueue(_ context.Context, job Job) error {
+	bs, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(q.pending), ".job-*")
+	if err != nil {
+		return fmt.Errorf("failed to write job: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write job: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write job: %v", err)
+	}
+
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), job.ID)
+	if err := os.Rename(tmp.Name(), filepath.Join(q.pending, name)); err != nil {
+		return fmt.Errorf("failed to write job: %v", err)
+	}
+	return nil
+}
+
+func (q *FileQueue) Receive(ctx context.Context) (*Delivery, error) {
+	q.recovered.Do(func() { q.recoverErr = q.requeueInflight() })
+	if q.recoverErr != nil {
+		return nil, q.recoverErr
+	}
+
+	for {
+		d, err := q.claim()
+		if err != nil || d != nil {
+			return d, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(q.poll):
+		}
+	}
+}
+
+// claim moves the oldest pending job into inflight/ and returns it, or nil if none is pending.
+func (q *FileQueue) claim() (*Delivery, error) {
+	entries, err := os.ReadDir(q.pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue directory: %v", err)
+	}
+
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(q.inflight, e.Name())
+		if err := os.Rename(filepath.Join(q.pending, e.Name()), path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to claim %s: %v", e.Name(), err)
+		}
+
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", e.Name(), err)
+		}
+		var job Job
+		if err := json.Unmarshal(bs, &job); err != nil {
+			// A file that never decodes would otherwise stop every consumer; set it aside and move on.
+			log.Printf("moving undecodable job %s to %s: %v", e.Name(), q.dead, err)
+			if err := os.Rename(path, filepath.Join(q.dead, e.Name())); err != nil {
+				return nil, fmt.Errorf("failed to dead-letter %s: %v", e.Name(), err)
+			}
+			continue
+		}
+		return &Delivery{Job: job, receipt: path}, nil
+	}
+
+	return nil, nil
+}
+
+func (q *FileQueue) Ack(_ context.Context, d *Delivery) error {
+	if err := os.Remove(d.receipt); err != nil {
+		return fmt.Errorf("failed to ack job %s: %v", d.Job.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package verify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+)
+
+// Job asks the verifier to confirm that an object reached every one of its targets.
+type Job struct {
+	ID      string         `json:"id"`
+	Targets []v1.TargetRef `json:"targets"`
+	// ContentType is the declared content type. A target's Options.ContentType overrides it.
+	ContentType string `json:"content_type,omitempty"`
+	// ContentLength and ContentMD5 are the client's expectations, if it supplied any. ContentMD5 is
+	// base64-encoded, as in the Content-MD5 header.
+	ContentLength int64  `json:"content_length,omitempty"`
+	ContentMD5    string `json:"content_md5,omitempty"`
+	// WriteQuorum is how many primaries the upload needs confirmed. Zero means all of them.
+	WriteQuorum int `json:"write_quorum,omitempty"`
+	// Deadline is when a replica that still has not appeared is reported as missing.
+	Deadline   time.Time `json:"deadline"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+}
+
+// TargetContentType returns the content type t was signed with.
+func (j Job) TargetContentType(t v1.TargetRef) string {
+	if t.Options != nil && t.Options.ContentType != "" {
+		return t.Options.ContentType
+	}
+	return j.ContentType
+}
+
+// NewJobID returns a random identifier for a Job.
+func NewJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Delivery is a job handed to a consumer. It stays reserved for that consumer until it is acked.
+type Delivery struct {
+	Job     Job
+	receipt string
+}
+
+// Queue carries verification jobs from the gateway to the verifier. Jobs that are received but never
+// acked are eventually delivered again, so consumers must tolerate duplicates.
+type Queue interface {
+	// Enqueue adds job to the queue.
+	Enqueue(ctx context.Context, job Job) error
+	// Receive blocks until a job is available or ctx is done.
+	Receive(ctx context.Context) (*Delivery, error)
+	// Ack removes a received job from the queue for good.
+	Ack(ctx context.Context, d *Delivery) error
+}
+
+// Leaser is implemented by queues that only hide a received job from other consumers for a limited
+// time. The verifier extends the lease while it checks a job so that the job is not delivered again in
+// the meantime.
+type Leaser interface {
+	// Lease is how long a received or extended job stays hidden.
+	Lease() time.Duration
+	// Extend hides d for another Lease from now.
+	Extend(ctx context.Context, d *Delivery) error
+}
+
+// MemoryQueue is an in-process Queue for tests and single-binary deployments. Jobs do not survive a
+// restart, and unacked jobs are only redelivered once Requeue is called.
+type MemoryQueue struct {
+	mu       sync.Mutex
+	pending  []Job
+	inflight map[string]Job
+	ready    chan struct{}
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		inflight: make(map[string]Job),
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+func (q *MemoryQueue) Enqueue(_ context.Context, job Job) error {
+	q.mu.Lock()
+	q.pending = append(q.pending, job)
+	q.mu.Unlock()
+
+	q.signal()
+	return nil
+}
+
+func (q *MemoryQueue) Receive(ctx context.Context) (*Delivery, error) {
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			job := q.pending[0]
+			q.pending = q.pending[1:]
+			q.inflight[job.ID] = job
+			more := len(q.pending) > 0
+			q.mu.Unlock()
+
+			if more {
+				q.signal()
+			}
+			return &Delivery{Job: job, receipt: job.ID}, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.ready:
+		}
+	}
+}
+
+func (q *MemoryQueue) Ack(_ context.Context, d *Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.inflight[d.receipt]; !ok {
+		return fmt.Errorf("job %s is not in flight", d.receipt)
+	}
+	delete(q.inflight, d.receipt)
+	return nil
+}
+
+// Requeue makes every received but unacked job available again.
+func (q *MemoryQueue) Requeue() {
+	q.mu.Lock()
+	for id, job := range q.inflight {
+		q.pending = append(q.pending, job)
+		delete(q.inflight, id)
+	}
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// Len returns the number of jobs waiting to be received.
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+func (q *MemoryQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,150 @@
+package verify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Queue", func() {
+	job := func(id string) Job {
+		return Job{
+			ID:       id,
+			Targets:  []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bucket", Key: id}},
+			Deadline: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+	}
+
+	type queueFactory func() Queue
+
+	DescribeTable("delivers jobs in order and forgets acked ones",
+		func(newQueue queueFactory) {
+			ctx := context.Background()
+			q := newQueue()
+
+			Expect(q.Enqueue(ctx, job("a"))).To(Succeed())
+			Expect(q.Enqueue(ctx, job("b"))).To(Succeed())
+
+			first, err := q.Receive(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.Job).To(Equal(job("a")))
+			Expect(q.Ack(ctx, first)).To(Succeed())
+
+			second, err := q.Receive(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second.Job.ID).To(Equal("b"))
+			Expect(q.Ack(ctx, second)).To(Succeed())
+
+			short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			_, err = q.Receive(short)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		},
+
+		Entry("memory", queueFactory(func() Queue { return NewMemoryQueue() })),
+		Entry("file", queueFactory(func() Queue {
+			q, err := NewFileQueue(GinkgoT().TempDir())
+			Expect(err).NotTo(HaveOccurred())
+			return q
+		})),
+	)
+
+	It("memory: Receive wakes up when a job is enqueued", func() {
+		q := NewMemoryQueue()
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(20 * time.Millisecond)
+			Expect(q.Enqueue(context.Background(), job("late"))).To(Succeed())
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		d, err := q.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Job.ID).To(Equal("late"))
+	})
+
+	It("memory: Requeue redelivers unacked jobs", func() {
+		ctx := context.Background()
+		q := NewMemoryQueue()
+		Expect(q.Enqueue(ctx, job("a"))).To(Succeed())
+
+		_, err := q.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(q.Len()).To(BeZero())
+
+		q.Requeue()
+		d, err := q.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Job.ID).To(Equal("a"))
+	})
+
+	It("file: reopening the queue redelivers jobs left in flight", func() {
+		ctx := context.Background()
+		dir := GinkgoT().TempDir()
+
+		q, err := NewFileQueue(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(q.Enqueue(ctx, job("a"))).To(Succeed())
+		_, err = q.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		pending, err := os.ReadDir(filepath.Join(dir, "pending"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeEmpty())
+
+		reopened, err := NewFileQueue(dir)
+		Expect(err).NotTo(HaveOccurred())
+		d, err := reopened.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Job.ID).To(Equal("a"))
+		Expect(reopened.Ack(ctx, d)).To(Succeed())
+
+		inflight, err := os.ReadDir(filepath.Join(dir, "inflight"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inflight).To(BeEmpty())
+	})
+
+	It("file: a producer opening the queue leaves jobs in flight alone", func() {
+		ctx := context.Background()
+		dir := GinkgoT().TempDir()
+
+		consumer, err := NewFileQueue(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(consumer.Enqueue(ctx, job("a"))).To(Succeed())
+		d, err := consumer.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		producer, err := NewFileQueue(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(producer.Enqueue(ctx, job("b"))).To(Succeed())
+
+		pending, err := os.ReadDir(filepath.Join(dir, "pending"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(HaveLen(1))
+		Expect(consumer.Ack(ctx, d)).To(Succeed())
+	})
+
+	It("file: sets aside jobs that do not decode and keeps receiving", func() {
+		ctx := context.Background()
+		dir := GinkgoT().TempDir()
+
+		q, err := NewFileQueue(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "pending", "00000000000000000000-bad.json"), []byte("{"), 0o600)).To(Succeed())
+		Expect(q.Enqueue(ctx, job("a"))).To(Succeed())
+
+		d, err := q.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Job.ID).To(Equal("a"))
+
+		dead, err := os.ReadDir(filepath.Join(dir, "dead"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dead).To(ConsistOf(HaveField("Name()", "00000000000000000000-bad.json")))
+	})
+})
@@ -0,0 +1,417 @@
+package verify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+)
+
+// md5Extractor returns the base64 MD5 digest a provider reports in a HEAD response, if it reports one.
+type md5Extractor func(h http.Header) (string, bool)
+
+// md5Extractors read object digests from HEAD responses. S3 only exposes the MD5 as the ETag of
+// single-part, non-KMS uploads; Azure returns Content-MD5; GCS lists it in x-goog-hash.
+var md5Extractors = map[v1.Provider]md5Extractor{
+	v1.ProviderAWS: func(h http.Header) (string, bool) {
+		etag := strings.Trim(h.Get("ETag"), `"`)
+		sum, err := hex.DecodeString(etag)
+		if err != nil || len(sum) != 16 || h.Get("x-amz-server-side-encryption") == "aws:kms" {
+			return "", false
+		}
+		return base64.StdEncoding.EncodeToString(sum), true
+	},
+	v1.ProviderAzure: func(h http.Header) (string, bool) {
+		sum := h.Get("Content-MD5")
+		return sum, sum != ""
+	},
+	v1.ProviderGCP: func(h http.Header) (string, bool) {
+		for _, v := range h.Values("x-goog-hash") {
+			for _, part := range strings.Split(v, ",") {
+				if sum, ok := strings.CutPrefix(strings.TrimSpace(part), "md5="); ok {
+					return sum, true
+				}
+			}
+		}
+		return "", false
+	},
+}
+
+// ReportedMD5 returns the base64 MD5 digest provider reports in the headers of an object response, if it
+// reports one.
+func ReportedMD5(provider v1.Provider, h http.Header) (string, bool) {
+	extract, ok := md5Extractors[provider]
+	if !ok {
+		return "", false
+	}
+	return extract(h)
+}
+
+// StatusFunc receives the final replication status of every job.
+type StatusFunc func(ctx context.Context, st v1.ReplicationStatus)
+
+// Repairer writes the object at src over dst. The verifier uses it to fill async targets, and primaries
+// left missing by a write quorum, from a confirmed primary.
+type Repairer interface {
+	Repair(ctx context.Context, src, dst v1.TargetRef) error
+}
+
+// ReplicationVerifier checks each target of a Job through a presigned HEAD and compares what the provider
+// reports with the size and checksum the client declared. Replicas that have not appeared yet are checked
+// again with exponential backoff until the job deadline.
+type ReplicationVerifier struct {
+	signers    presign.Registry
+	client     *http.Client
+	ttl        time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onStatus   StatusFunc
+	repairer   Repairer
+	contents   *ContentTypeVerifier
+}
+
+// ReplicationOption mutates a ReplicationVerifier.
+type ReplicationOption func(*ReplicationVerifier)
+
+// WithReplicationHTTPClient sets the client used for HEAD requests.
+func WithReplicationHTTPClient(c *http.Client) ReplicationOption {
+	return func(v *ReplicationVerifier) { v.client = c }
+}
+
+// WithBackoff sets the first and the longest delay between checks of a job.
+func WithBackoff(minDelay, maxDelay time.Duration) ReplicationOption {
+	return func(v *ReplicationVerifier) {
+		v.minBackoff = minDelay
+		v.maxBackoff = maxDelay
+	}
+}
+
+// WithOnStatus sets the hook that receives the final status of every job.
+func WithOnStatus(fn StatusFunc) ReplicationOption {
+	return func(v *ReplicationVerifier) { v.onStatus = fn }
+}
+
+// WithRepairer sets what fills async targets and missing primaries. Without one, they are only checked.
+func WithRepairer(r Repairer) ReplicationOption {
+	return func(v *ReplicationVerifier) { v.repairer = r }
+}
+
+// WithContentTypeCheck sniffs every replica that matches its size and checksum once a job is done, and
+// marks those whose content does not match the type they were signed with as mismatched. The hook set
+// with WithOnMismatch on c is run for each of them.
+func WithContentTypeCheck(c *ContentTypeVerifier) ReplicationOption {
+	return func(v *ReplicationVerifier) { v.contents = c }
+}
+
+func NewReplicationVerifier(signers presign.Registry, opts ...ReplicationOption) *ReplicationVerifier {
+	v := &ReplicationVerifier{
+		signers:    signers,
+		client:     http.DefaultClient,
+		ttl:        time.Minute,
+		minBackoff: 5 * time.Second,
+		maxBackoff: time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify checks job until every replica exists or the deadline passes, and returns the last status. A
+// mismatched replica is final and is not checked again. With a Repairer, missing async targets, and
+// missing primaries once the write quorum is met, are filled from a confirmed primary after each check.
+// With a content type check, the replicas of the last status are sniffed before it is returned. Verify
+// returns early with ctx.Err() if ctx is done before a final status is reached.
+func (v *ReplicationVerifier) Verify(ctx context.Context, job Job) (v1.ReplicationStatus, error) {
+	delay := v.minBackoff
+	for attempt := 1; ; attempt++ {
+		st := v.Check(ctx, job)
+		st.Attempts = attempt
+		if v.repairer != nil {
+			v.repair(ctx, job, &st)
+		}
+
+		if st.State == v1.ReplicationComplete || st.State == v1.ReplicationMismatched ||
+			!time.Now().Add(delay).Before(job.Deadline) {
+			if v.contents != nil {
+				v.checkContentTypes(ctx, job, &st)
+			}
+			return st, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return st, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > v.maxBackoff {
+			delay = v.maxBackoff
+		}
+	}
+}
+
+// repair fills every missing async replica of st from the first confirmed primary and checks it again.
+// When the job has a write quorum below its number of primaries and enough of them are confirmed, the
+// missing primaries are filled the same way. A failed repair is recorded on the replica and tried again on
+// the next attempt.
+func (v *ReplicationVerifier) repair(ctx context.Context, job Job, st *v1.ReplicationStatus) {
+	var (
+		src                  *v1.TargetRef
+		primaries, confirmed int
+	)
+	for _, r := range st.Replicas {
+		if r.TargetRef.Role == v1.RoleAsync {
+			continue
+		}
+		primaries++
+		if r.Exists && r.Mismatch == "" {
+			confirmed++
+			if src == nil {
+				src = &r.TargetRef
+			}
+		}
+	}
+	if src == nil {
+		return
+	}
+	fillPrimaries := job.WriteQuorum > 0 && job.WriteQuorum < primaries && confirmed >= job.WriteQuorum
+
+	repaired := false
+	for i, r := range st.Replicas {
+		if r.Exists || r.Error != "" {
+			continue
+		}
+		if r.TargetRef.Role != v1.RoleAsync && !fillPrimaries {
+			continue
+		}
+		if err := v.repairer.Repair(ctx, *src, r.TargetRef); err != nil {
+			st.Replicas[i].Error = fmt.Sprintf("repair from %s/%s failed: %v", src.Provider, src.Bucket, err)
+			continue
+		}
+		st.Replicas[i] = v.checkReplica(ctx, job, r.TargetRef)
+		repaired = true
+	}
+	if repaired {
+		st.State = Summarize(st.Replicas)
+		st.CheckedAt = time.Now().UTC()
+	}
+}
+
+// checkContentTypes sniffs every replica of st that exists without a mismatch, comparing it with the
+// content type its target was signed with. Targets without a declared type are not sniffed.
+func (v *ReplicationVerifier) checkContentTypes(ctx context.Context, job Job, st *v1.ReplicationStatus) {
+	checked := false
+	for i, r := range st.Replicas {
+		declared := job.TargetContentType(r.TargetRef)
+		if !r.Exists || r.Mismatch != "" || declared == "" {
+			continue
+		}
+
+		res := v.contents.Check(ctx, declared, r.TargetRef)
+		switch {
+		case res.Err != nil:
+			st.Replicas[i].Error = fmt.Sprintf("content type check failed: %v", res.Err)
+		case !res.Match:
+			st.Replicas[i].Mismatch = fmt.Sprintf("content type %s, expected %s", res.Detected, declared)
+		}
+		checked = true
+	}
+	if checked {
+		st.State = Summarize(st.Replicas)
+	}
+}
+
+// Check runs a single HEAD against every target of job.
+func (v *ReplicationVerifier) Check(ctx context.Context, job Job) v1.ReplicationStatus {
+	replicas := make([]v1.ReplicaStatus, len(job.Targets))
+	for i, t := range job.Targets {
+		replicas[i] = v.checkReplica(ctx, job, t)
+	}
+
+	return v1.ReplicationStatus{
+		VerificationID: job.ID,
+		State:          Summarize(replicas),
+		Replicas:       replicas,
+		CheckedAt:      time.Now().UTC(),
+	}
+}
+
+func (v *ReplicationVerifier) checkReplica(ctx context.Context, job Job, t v1.TargetRef) v1.ReplicaStatus {
+	rs := v1.ReplicaStatus{TargetRef: t}
+
+	res, err := v.head(ctx, t)
+	if err != nil {
+		rs.Error = err.Error()
+		return rs
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return rs
+	case res.StatusCode/100 != 2:
+		rs.Error = fmt.Sprintf("head failed: %s", res.Status)
+		return rs
+	}
+
+	rs.Exists = true
+	rs.ETag = res.Header.Get("ETag")
+	if n, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64); err == nil {
+		rs.ContentLength = n
+	}
+
+	if job.ContentLength > 0 && rs.ContentLength != job.ContentLength {
+		rs.Mismatch = fmt.Sprintf("content length %d, expected %d", rs.ContentLength, job.ContentLength)
+		return rs
+	}
+	if job.ContentMD5 != "" {
+		if sum, ok := ReportedMD5(t.Provider, res.Header); ok && sum != job.ContentMD5 {
+			rs.Mismatch = fmt.Sprintf("content md5 %s, expected %s", sum, job.ContentMD5)
+		}
+	}
+	return rs
+}
+
+func (v *ReplicationVerifier) head(ctx context.Context, t v1.TargetRef) (*http.Response, error) {
+	presigner, ok := v.signers[t.Provider]
+	if !ok {
+		return nil, fmt.Errorf("provider not configured: %s", t.Provider)
+	}
+
+	opts := presign.NewHeadOptions(
+		presign.WithHeadTTL(v.ttl),
+		presign.WithHeadVersion(t.VersionID),
+	)
+	u, err := presigner.PresignHead(ctx, t.Bucket, t.Key, opts)
+	if err != nil {
+		return nil, fmt.Errorf("presign failed for %s: %w", t.Provider, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range u.HeaderValues {
+		for _, val := range vals {
+			req.Header.Add(k, val)
+		}
+	}
+	return v.client.Do(req)
+}
+
+// Summarize reduces replicas to an object state: any mismatch wins, then complete, missing or partial
+// depending on how many replicas exist.
+func Summarize(replicas []v1.ReplicaStatus) v1.ReplicationState {
+	exists := 0
+	for _, r := range replicas {
+		if r.Mismatch != "" {
+			return v1.ReplicationMismatched
+		}
+		if r.Exists {
+			exists++
+		}
+	}
+
+	switch exists {
+	case len(replicas):
+		return v1.ReplicationComplete
+	case 0:
+		return v1.ReplicationMissing
+	default:
+		return v1.ReplicationPartial
+	}
+}
+
+// Consume verifies jobs from q on the given number of workers until ctx is done. Each job is acked once
+// its final status has been reported, and its lease is extended while it is checked if q leases jobs. A
+// job interrupted by shutdown is left for redelivery.
+func (v *ReplicationVerifier) Consume(ctx context.Context, q Queue, workers int) error {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v.consume(ctx, q)
+		}()
+	}
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// consume verifies jobs until ctx is done. Queue errors are logged and retried with backoff rather than
+// stopping the worker; a job whose ack fails is delivered again later.
+func (v *ReplicationVerifier) consume(ctx context.Context, q Queue) {
+	delay := v.minBackoff
+	for {
+		d, err := q.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("failed to receive job, retrying in %s: %v", delay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, v.maxBackoff)
+			continue
+		}
+		delay = v.minBackoff
+
+		release := hold(ctx, q, d)
+		st, err := v.Verify(ctx, d.Job)
+		release()
+		if err != nil {
+			return
+		}
+		if v.onStatus != nil {
+			v.onStatus(ctx, st)
+		}
+		if err := q.Ack(ctx, d); err != nil {
+			log.Printf("failed to ack job %s: %v", d.Job.ID, err)
+		}
+	}
+}
+
+// hold keeps extending the lease on d, if q leases jobs, until the returned function is called.
+func hold(ctx context.Context, q Queue, d *Delivery) func() {
+	l, ok := q.(Leaser)
+	if !ok {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tick := time.NewTicker(l.Lease() / 3)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if err := l.Extend(ctx, d); err != nil && ctx.Err() == nil {
+					log.Printf("failed to extend lease on job %s: %v", d.Job.ID, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,471 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	"github.com/jordanharrington/bsync/internal/presign"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// emptyMD5 is the base64 MD5 of an empty payload, d41d8cd98f00b204e9800998ecf8427e in hex.
+const emptyMD5 = "1B2M2Y8AsgTpgAmY7PhCfg=="
+
+// headPresigner signs HEADs as plain URLs on a local stand-in for a provider.
+type headPresigner struct {
+	presign.Presigner
+	base string
+}
+
+func (p headPresigner) PresignHead(_ context.Context, bucket, key string, _ presign.HeadOptions) (*v1.PresignedUrl, error) {
+	return &v1.PresignedUrl{URL: p.base + "/" + bucket + "/" + key, Method: http.MethodHead}, nil
+}
+
+func (p headPresigner) PresignGet(_ context.Context, bucket, key string, _ presign.GetOptions) (*v1.PresignedUrl, error) {
+	return &v1.PresignedUrl{URL: p.base + "/" + bucket + "/" + key, Method: http.MethodGet}, nil
+}
+
+// repairFunc adapts a function to Repairer.
+type repairFunc func(ctx context.Context, src, dst v1.TargetRef) error
+
+func (f repairFunc) Repair(ctx context.Context, src, dst v1.TargetRef) error { return f(ctx, src, dst) }
+
+// flakyQueue fails its first Receive and first Ack, and leases jobs for a short time.
+type flakyQueue struct {
+	*MemoryQueue
+	mu                  sync.Mutex
+	receives, acks, ext int
+}
+
+func (q *flakyQueue) Receive(ctx context.Context) (*Delivery, error) {
+	q.mu.Lock()
+	q.receives++
+	first := q.receives == 1
+	q.mu.Unlock()
+	if first {
+		return nil, errors.New("connection reset")
+	}
+	return q.MemoryQueue.Receive(ctx)
+}
+
+func (q *flakyQueue) Ack(ctx context.Context, d *Delivery) error {
+	q.mu.Lock()
+	q.acks++
+	first := q.acks == 1
+	q.mu.Unlock()
+	if first {
+		return errors.New("receipt handle expired")
+	}
+	return q.MemoryQueue.Ack(ctx, d)
+}
+
+func (q *flakyQueue) Lease() time.Duration { return 30 * time.Millisecond }
+
+func (q *flakyQueue) Extend(context.Context, *Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ext++
+	return nil
+}
+
+func (q *flakyQueue) extensions() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.ext
+}
+
+var _ = Describe("ReplicationVerifier", func() {
+	var (
+		ctx     context.Context
+		signers presign.Registry
+		srv     *httptest.Server
+		mu      sync.Mutex
+		objects map[string]http.Header
+		bodies  map[string][]byte
+	)
+
+	// put makes path answer HEAD requests with hdr, the way the provider stand-in would.
+	put := func(path string, hdr http.Header) {
+		mu.Lock()
+		defer mu.Unlock()
+		objects[path] = hdr
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		objects = map[string]http.Header{}
+		bodies = map[string][]byte{}
+
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Method).To(BeElementOf(http.MethodHead, http.MethodGet))
+			mu.Lock()
+			hdr, ok := objects[r.URL.Path]
+			body := bodies[r.URL.Path]
+			mu.Unlock()
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			for k, v := range hdr {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(body)
+			}
+		}))
+		DeferCleanup(srv.Close)
+
+		signers = presign.Registry{}
+		for _, p := range []v1.Provider{v1.ProviderAWS, v1.ProviderAzure, v1.ProviderGCP} {
+			signers[p] = headPresigner{base: srv.URL + "/" + string(p)}
+		}
+	})
+
+	job := func(targets ...v1.TargetRef) Job {
+		return Job{
+			ID:         "j1",
+			Targets:    targets,
+			ContentMD5: emptyMD5,
+			Deadline:   time.Now().Add(time.Second),
+		}
+	}
+	aws := v1.TargetRef{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "k"}
+	azure := v1.TargetRef{Provider: v1.ProviderAzure, Bucket: "bucket", Key: "k"}
+	gcp := v1.TargetRef{Provider: v1.ProviderGCP, Bucket: "bucket", Key: "k"}
+
+	type checkTestCase struct {
+		stored      map[string]http.Header
+		expectState v1.ReplicationState
+		expectWhy   string
+	}
+
+	DescribeTable("Check",
+		func(tc checkTestCase) {
+			for path, hdr := range tc.stored {
+				put(path, hdr)
+			}
+
+			v := NewReplicationVerifier(signers, WithReplicationHTTPClient(srv.Client()))
+			st := v.Check(ctx, job(aws, azure, gcp))
+
+			Expect(st.VerificationID).To(Equal("j1"))
+			Expect(st.State).To(Equal(tc.expectState))
+			Expect(st.Replicas).To(HaveLen(3))
+			if tc.expectWhy != "" {
+				Expect(st.Replicas).To(ContainElement(HaveField("Mismatch", ContainSubstring(tc.expectWhy))))
+			}
+		},
+
+		Entry("complete: every provider reports the expected digest", checkTestCase{
+			stored: map[string]http.Header{
+				"/aws/bucket/k":   {"Etag": {`"d41d8cd98f00b204e9800998ecf8427e"`}},
+				"/azure/bucket/k": {"Content-Md5": {emptyMD5}},
+				"/gcp/bucket/k":   {"X-Goog-Hash": {"crc32c=AAAAAA==", "md5=" + emptyMD5}},
+			},
+			expectState: v1.ReplicationComplete,
+		}),
+		Entry("complete: a multipart ETag cannot be compared and is accepted", checkTestCase{
+			stored: map[string]http.Header{
+				"/aws/bucket/k":   {"Etag": {`"9b2cf535f27731c974343645a3985328-2"`}},
+				"/azure/bucket/k": {},
+				"/gcp/bucket/k":   {},
+			},
+			expectState: v1.ReplicationComplete,
+		}),
+		Entry("partial: one replica has not landed", checkTestCase{
+			stored: map[string]http.Header{
+				"/aws/bucket/k":   {},
+				"/azure/bucket/k": {},
+			},
+			expectState: v1.ReplicationPartial,
+		}),
+		Entry("missing: no replica exists", checkTestCase{
+			expectState: v1.ReplicationMissing,
+		}),
+		Entry("mismatched: a replica has a different digest", checkTestCase{
+			stored: map[string]http.Header{
+				"/aws/bucket/k":   {},
+				"/azure/bucket/k": {"Content-Md5": {"XUFAKrxLKna5cZ2REBfFkg=="}},
+				"/gcp/bucket/k":   {},
+			},
+			expectState: v1.ReplicationMismatched,
+			expectWhy:   "content md5 XUFAKrxLKna5cZ2REBfFkg==",
+		}),
+	)
+
+	It("reports a size mismatch", func() {
+		put("/aws/bucket/k", http.Header{"Content-Length": {"512"}})
+
+		v := NewReplicationVerifier(signers, WithReplicationHTTPClient(srv.Client()))
+		j := job(aws)
+		j.ContentLength = 1024
+		st := v.Check(ctx, j)
+
+		Expect(st.State).To(Equal(v1.ReplicationMismatched))
+		Expect(st.Replicas[0].Mismatch).To(Equal("content length 512, expected 1024"))
+	})
+
+	It("reports unconfigured providers as errors", func() {
+		v := NewReplicationVerifier(presign.Registry{}, WithReplicationHTTPClient(srv.Client()))
+		st := v.Check(ctx, job(aws))
+
+		Expect(st.State).To(Equal(v1.ReplicationMissing))
+		Expect(st.Replicas[0].Error).To(Equal("provider not configured: aws"))
+	})
+
+	It("retries with backoff until a late replica lands", func() {
+		put("/aws/bucket/k", http.Header{})
+		time.AfterFunc(30*time.Millisecond, func() { put("/gcp/bucket/k", http.Header{}) })
+
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+		)
+		st, err := v.Verify(ctx, job(aws, gcp))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationComplete))
+		Expect(st.Attempts).To(BeNumerically(">", 1))
+	})
+
+	It("gives up at the deadline", func() {
+		put("/aws/bucket/k", http.Header{})
+
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+		)
+		j := job(aws, gcp)
+		j.Deadline = time.Now().Add(50 * time.Millisecond)
+		st, err := v.Verify(ctx, j)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationPartial))
+		Expect(time.Now()).To(BeTemporally("<", j.Deadline.Add(50*time.Millisecond)))
+	})
+
+	It("fills async targets from a confirmed primary", func() {
+		put("/aws/bucket/k", http.Header{})
+		async := gcp
+		async.Role = v1.RoleAsync
+
+		var repaired []v1.TargetRef
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithRepairer(repairFunc(func(_ context.Context, src, dst v1.TargetRef) error {
+				Expect(src).To(Equal(aws))
+				repaired = append(repaired, dst)
+				put("/gcp/bucket/k", http.Header{})
+				return nil
+			})),
+		)
+		st, err := v.Verify(ctx, job(aws, async))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationComplete))
+		Expect(st.Attempts).To(Equal(1))
+		Expect(repaired).To(ConsistOf(async))
+	})
+
+	It("does not repair primaries or fill from a missing source", func() {
+		async := gcp
+		async.Role = v1.RoleAsync
+
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+			WithRepairer(repairFunc(func(context.Context, v1.TargetRef, v1.TargetRef) error {
+				Fail("unexpected repair")
+				return nil
+			})),
+		)
+		j := job(aws, async)
+		j.Deadline = time.Now().Add(30 * time.Millisecond)
+		st, err := v.Verify(ctx, j)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationMissing))
+	})
+
+	It("records a failed repair on the replica", func() {
+		put("/aws/bucket/k", http.Header{})
+		async := gcp
+		async.Role = v1.RoleAsync
+
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+			WithRepairer(repairFunc(func(context.Context, v1.TargetRef, v1.TargetRef) error {
+				return errors.New("copy denied")
+			})),
+		)
+		j := job(aws, async)
+		j.Deadline = time.Now().Add(30 * time.Millisecond)
+		st, err := v.Verify(ctx, j)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationPartial))
+		Expect(st.Replicas[1].Error).To(Equal("repair from aws/bucket failed: copy denied"))
+	})
+
+	It("fills missing primaries once the write quorum is met", func() {
+		put("/aws/bucket/k", http.Header{})
+
+		var repaired []v1.TargetRef
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithRepairer(repairFunc(func(_ context.Context, src, dst v1.TargetRef) error {
+				Expect(src).To(Equal(aws))
+				repaired = append(repaired, dst)
+				put("/gcp/bucket/k", http.Header{})
+				return nil
+			})),
+		)
+		j := job(aws, gcp)
+		j.WriteQuorum = 1
+		st, err := v.Verify(ctx, j)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationComplete))
+		Expect(repaired).To(ConsistOf(gcp))
+	})
+
+	It("does not fill primaries before the write quorum is met", func() {
+		put("/aws/bucket/k", http.Header{})
+
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+			WithRepairer(repairFunc(func(context.Context, v1.TargetRef, v1.TargetRef) error {
+				Fail("unexpected repair")
+				return nil
+			})),
+		)
+		j := job(aws, azure, gcp)
+		j.WriteQuorum = 2
+		j.Deadline = time.Now().Add(30 * time.Millisecond)
+		st, err := v.Verify(ctx, j)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationPartial))
+	})
+
+	It("flags replicas whose content does not match the declared type", func() {
+		put("/aws/bucket/k", http.Header{})
+		put("/gcp/bucket/k", http.Header{})
+		mu.Lock()
+		bodies["/aws/bucket/k"] = pngBytes
+		bodies["/gcp/bucket/k"] = elfBytes
+		mu.Unlock()
+
+		var flagged []ContentTypeResult
+		contents := NewContentTypeVerifier(signers,
+			WithHTTPClient(srv.Client()),
+			WithOnMismatch(func(_ context.Context, res ContentTypeResult) {
+				flagged = append(flagged, res)
+			}),
+		)
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithContentTypeCheck(contents),
+		)
+
+		j := job(aws, gcp)
+		j.ContentMD5 = ""
+		j.ContentType = "image/png"
+		st, err := v.Verify(ctx, j)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationMismatched))
+		Expect(st.Replicas[0].Mismatch).To(BeEmpty())
+		Expect(st.Replicas[1].Mismatch).To(Equal("content type application/octet-stream, expected image/png"))
+		Expect(flagged).To(HaveLen(1))
+		Expect(flagged[0].Target.Provider).To(Equal(v1.ProviderGCP))
+	})
+
+	It("sniffs each replica against the content type its target was signed with", func() {
+		put("/aws/bucket/k", http.Header{})
+		put("/gcp/bucket/k", http.Header{})
+		mu.Lock()
+		bodies["/aws/bucket/k"] = pngBytes
+		bodies["/gcp/bucket/k"] = []byte(`{"key":"value"}`)
+		mu.Unlock()
+
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithContentTypeCheck(NewContentTypeVerifier(signers, WithHTTPClient(srv.Client()))),
+		)
+
+		signedAsJSON := gcp
+		signedAsJSON.Options = &v1.TargetOptions{ContentType: "application/json"}
+		j := job(aws, signedAsJSON)
+		j.ContentMD5 = ""
+		j.ContentType = "image/png"
+		st, err := v.Verify(ctx, j)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(v1.ReplicationComplete))
+	})
+
+	It("consumes, reports and acks queued jobs", func() {
+		put("/aws/bucket/k", http.Header{})
+
+		q := NewMemoryQueue()
+		Expect(q.Enqueue(ctx, job(aws))).To(Succeed())
+
+		statuses := make(chan v1.ReplicationStatus, 1)
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithOnStatus(func(_ context.Context, st v1.ReplicationStatus) { statuses <- st }),
+		)
+
+		run, stop := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- v.Consume(run, q, 2) }()
+
+		var st v1.ReplicationStatus
+		Eventually(statuses).Should(Receive(&st))
+		Expect(st.State).To(Equal(v1.ReplicationComplete))
+
+		stop()
+		Eventually(done).Should(Receive(MatchError(context.Canceled)))
+
+		q.Requeue()
+		Expect(q.Len()).To(BeZero())
+	})
+
+	It("keeps consuming through queue errors and extends leases while checking", func() {
+		q := &flakyQueue{MemoryQueue: NewMemoryQueue()}
+		for _, id := range []string{"j1", "j2"} {
+			j := job(aws)
+			j.ID = id
+			j.Deadline = time.Now().Add(100 * time.Millisecond)
+			Expect(q.Enqueue(ctx, j)).To(Succeed())
+		}
+
+		statuses := make(chan v1.ReplicationStatus, 2)
+		v := NewReplicationVerifier(signers,
+			WithReplicationHTTPClient(srv.Client()),
+			WithBackoff(5*time.Millisecond, 10*time.Millisecond),
+			WithOnStatus(func(_ context.Context, st v1.ReplicationStatus) { statuses <- st }),
+		)
+
+		run, stop := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- v.Consume(run, q, 1) }()
+
+		Eventually(statuses).Should(Receive(HaveField("VerificationID", "j1")))
+		Eventually(statuses).Should(Receive(HaveField("VerificationID", "j2")))
+		Expect(q.extensions()).To(BeNumerically(">", 0))
+
+		stop()
+		Eventually(done).Should(Receive(MatchError(context.Canceled)))
+	})
+})
@@ -0,0 +1,154 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/samber/lo"
+)
+
+type sqsAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+const (
+	// sqsWaitSeconds is the long-poll duration of each ReceiveMessage call, the maximum SQS allows.
+	sqsWaitSeconds = 20
+	// sqsLeaseSeconds is the visibility timeout set on every received job and every extension of it, so
+	// the queue's own default does not matter.
+	sqsLeaseSeconds = 120
+)
+
+// SQSQueue is a Queue on Amazon SQS or any service that speaks its API (ElasticMQ, LocalStack).
+// Received jobs are hidden for two minutes at a time and reappear if their lease is not extended.
+// Messages that do not decode as a job are moved to the dead-letter queue, if one is set, and deleted.
+type SQSQueue struct {
+	client        sqsAPI
+	queueURL      string
+	deadLetterURL string
+}
+
+// NewSQSQueue uses the default AWS config. Point it at an SQS-compatible service by passing an option
+// that sets sqs.Options.BaseEndpoint.
+func NewSQSQueue(ctx context.Context, queueURL string, optFns ...func(*sqs.Options)) (*SQSQueue, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SQSQueue{client: sqs.NewFromConfig(cfg, optFns...), queueURL: queueURL}, nil
+}
+
+// SetDeadLetterQueue sets the queue that messages which do not decode as a job are moved to. Without one,
+// they are logged and deleted.
+func (q *SQSQueue) SetDeadLetterQueue(url string) {
+	q.deadLetterURL = url
+}
+
+func (q *SQSQueue) Enqueue(ctx context.Context, job Job) error {
+	bs, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %v", err)
+	}
+
+	_, err = q.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    &q.queueURL,
+		MessageBody: lo.ToPtr(string(bs)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send job %s: %v", job.ID, err)
+	}
+	return nil
+}
+
+func (q *SQSQueue) Receive(ctx context.Context) (*Delivery, error) {
+	for {
+		out, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            &q.queueURL,
+			MaxNumberOfMessages: 1,
+			WaitTimeSeconds:     sqsWaitSeconds,
+			VisibilityTimeout:   sqsLeaseSeconds,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to receive job: %v", err)
+		}
+		if len(out.Messages) == 0 {
+			continue
+		}
+
+		msg := out.Messages[0]
+		var job Job
+		if err := json.Unmarshal([]byte(lo.FromPtr(msg.Body)), &job); err != nil {
+			// A message that never decodes would otherwise stop every consumer; set it aside and move on.
+			if err := q.deadLetter(ctx, msg, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return &Delivery{Job: job, receipt: lo.FromPtr(msg.ReceiptHandle)}, nil
+	}
+}
+
+// deadLetter copies msg to the dead-letter queue, if one is set, and deletes it from the job queue.
+func (q *SQSQueue) deadLetter(ctx context.Context, msg types.Message, cause error) error {
+	id := lo.FromPtr(msg.MessageId)
+	if q.deadLetterURL == "" {
+		log.Printf("dropping undecodable message %s: %v: %s", id, cause, lo.FromPtr(msg.Body))
+	} else {
+		log.Printf("moving undecodable message %s to %s: %v", id, q.deadLetterURL, cause)
+		_, err := q.client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:    &q.deadLetterURL,
+			MessageBody: msg.Body,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to dead-letter message %s: %v", id, err)
+		}
+	}
+
+	_, err := q.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      &q.queueURL,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete message %s: %v", id, err)
+	}
+	return nil
+}
+
+func (q *SQSQueue) Ack(ctx context.Context, d *Delivery) error {
+	_, err := q.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      &q.queueURL,
+		ReceiptHandle: &d.receipt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to ack job %s: %v", d.Job.ID, err)
+	}
+	return nil
+}
+
+func (q *SQSQueue) Lease() time.Duration {
+	return sqsLeaseSeconds * time.Second
+}
+
+func (q *SQSQueue) Extend(ctx context.Context, d *Delivery) error {
+	_, err := q.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          &q.queueURL,
+		ReceiptHandle:     &d.receipt,
+		VisibilityTimeout: sqsLeaseSeconds,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extend job %s: %v", d.Job.ID, err)
+	}
+	return nil
+}
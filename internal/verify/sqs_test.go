@@ -0,0 +1,149 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	v1 "github.com/jordanharrington/bsync/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSQSAPI struct {
+	mock.Mock
+}
+
+func (m *mockSQSAPI) SendMessage(ctx context.Context, in *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*sqs.SendMessageOutput), args.Error(1)
+}
+
+func (m *mockSQSAPI) ReceiveMessage(ctx context.Context, in *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*sqs.ReceiveMessageOutput), args.Error(1)
+}
+
+func (m *mockSQSAPI) DeleteMessage(ctx context.Context, in *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*sqs.DeleteMessageOutput), args.Error(1)
+}
+
+func (m *mockSQSAPI) ChangeMessageVisibility(ctx context.Context, in *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	args := m.Called(ctx, in)
+
+	return args.Get(0).(*sqs.ChangeMessageVisibilityOutput), args.Error(1)
+}
+
+var _ = Describe("SQSQueue", func() {
+	var (
+		ctx context.Context
+		api *mockSQSAPI
+		q   *SQSQueue
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		api = &mockSQSAPI{}
+		q = &SQSQueue{client: api, queueURL: "https://sqs.local/jobs"}
+	})
+
+	It("sends, long-polls and deletes jobs", func() {
+		job := Job{ID: "j1", Targets: []v1.TargetRef{{Provider: v1.ProviderAWS, Bucket: "bucket", Key: "k"}}}
+		body, _ := json.Marshal(job)
+
+		api.
+			On("SendMessage", mock.Anything, mock.MatchedBy(func(in *sqs.SendMessageInput) bool {
+				return *in.QueueUrl == "https://sqs.local/jobs" && *in.MessageBody == string(body)
+			})).
+			Return(&sqs.SendMessageOutput{}, nil).
+			Once()
+		api.
+			On("ReceiveMessage", mock.Anything, mock.MatchedBy(func(in *sqs.ReceiveMessageInput) bool {
+				return in.WaitTimeSeconds == sqsWaitSeconds && in.VisibilityTimeout == sqsLeaseSeconds
+			})).
+			Return(&sqs.ReceiveMessageOutput{}, nil).
+			Once()
+		api.
+			On("ReceiveMessage", mock.Anything, mock.Anything).
+			Return(&sqs.ReceiveMessageOutput{Messages: []types.Message{{
+				Body:          aws.String(string(body)),
+				ReceiptHandle: aws.String("r1"),
+			}}}, nil).
+			Once()
+		api.
+			On("DeleteMessage", mock.Anything, mock.MatchedBy(func(in *sqs.DeleteMessageInput) bool {
+				return *in.ReceiptHandle == "r1"
+			})).
+			Return(&sqs.DeleteMessageOutput{}, nil).
+			Once()
+
+		Expect(q.Enqueue(ctx, job)).To(Succeed())
+
+		d, err := q.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Job).To(Equal(job))
+		Expect(q.Ack(ctx, d)).To(Succeed())
+
+		api.AssertNumberOfCalls(GinkgoT(), "ReceiveMessage", 2)
+		api.AssertExpectations(GinkgoT())
+	})
+
+	It("moves messages that do not decode to the dead-letter queue and keeps receiving", func() {
+		q.SetDeadLetterQueue("https://sqs.local/jobs-dlq")
+		body, _ := json.Marshal(Job{ID: "j1"})
+
+		api.
+			On("ReceiveMessage", mock.Anything, mock.Anything).
+			Return(&sqs.ReceiveMessageOutput{Messages: []types.Message{{
+				MessageId:     aws.String("m0"),
+				Body:          aws.String("not json"),
+				ReceiptHandle: aws.String("r0"),
+			}}}, nil).
+			Once()
+		api.
+			On("SendMessage", mock.Anything, mock.MatchedBy(func(in *sqs.SendMessageInput) bool {
+				return *in.QueueUrl == "https://sqs.local/jobs-dlq" && *in.MessageBody == "not json"
+			})).
+			Return(&sqs.SendMessageOutput{}, nil).
+			Once()
+		api.
+			On("DeleteMessage", mock.Anything, mock.MatchedBy(func(in *sqs.DeleteMessageInput) bool {
+				return *in.QueueUrl == "https://sqs.local/jobs" && *in.ReceiptHandle == "r0"
+			})).
+			Return(&sqs.DeleteMessageOutput{}, nil).
+			Once()
+		api.
+			On("ReceiveMessage", mock.Anything, mock.Anything).
+			Return(&sqs.ReceiveMessageOutput{Messages: []types.Message{{
+				Body:          aws.String(string(body)),
+				ReceiptHandle: aws.String("r1"),
+			}}}, nil).
+			Once()
+
+		d, err := q.Receive(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Job.ID).To(Equal("j1"))
+		api.AssertExpectations(GinkgoT())
+	})
+
+	It("extends a job's visibility by a full lease", func() {
+		api.
+			On("ChangeMessageVisibility", mock.Anything, mock.MatchedBy(func(in *sqs.ChangeMessageVisibilityInput) bool {
+				return *in.ReceiptHandle == "r1" && in.VisibilityTimeout == sqsLeaseSeconds
+			})).
+			Return(&sqs.ChangeMessageVisibilityOutput{}, nil).
+			Once()
+
+		Expect(q.Extend(ctx, &Delivery{Job: Job{ID: "j1"}, receipt: "r1"})).To(Succeed())
+		Expect(q.Lease()).To(Equal(2 * time.Minute))
+		api.AssertExpectations(GinkgoT())
+	})
+})